@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	MIMEJSON    = "application/json"
+	MIMEMsgPack = "application/msgpack"
+	MIMECBOR    = "application/cbor"
+)
+
+// Body encodings used by the adapter API. JSON stays the default; the binary
+// formats carry timestamps and byte slices natively:
+//
+//	time.Time  JSON: RFC 3339 string   MessagePack: timestamp ext (-1)   CBOR: tag 0 RFC 3339 string
+//	[]byte     JSON: base64 string     MessagePack: bin                   CBOR: byte string
+//	NULL       JSON: null              MessagePack: nil                   CBOR: null
+type Codec struct {
+	MIME   string
+	Decode func(r io.Reader, obj any) error
+	Encode func(obj any) ([]byte, error)
+}
+
+var cborEnc, _ = cbor.EncOptions{
+	Time:    cbor.TimeRFC3339Nano,
+	TimeTag: cbor.EncTagRequired,
+}.EncMode()
+
+var cborDec, _ = cbor.DecOptions{
+	DefaultMapType: reflect.TypeOf(map[string]interface{}(nil)),
+	IntDec:         cbor.IntDecConvertSigned,
+}.DecMode()
+
+var Codecs = map[string]*Codec{
+	MIMEJSON: {
+		MIME: MIMEJSON,
+		Decode: func(r io.Reader, obj any) error {
+			return json.NewDecoder(r).Decode(obj)
+		},
+		Encode: json.Marshal,
+	},
+	MIMEMsgPack: {
+		MIME: MIMEMsgPack,
+		Decode: func(r io.Reader, obj any) error {
+			dec := msgpack.NewDecoder(r)
+			dec.SetCustomStructTag("json")
+			return dec.Decode(obj)
+		},
+		Encode: func(obj any) ([]byte, error) {
+			var buffer bytes.Buffer
+			enc := msgpack.NewEncoder(&buffer)
+			enc.SetCustomStructTag("json")
+			enc.UseCompactInts(true)
+			if err := enc.Encode(obj); err != nil {
+				return nil, err
+			}
+			return buffer.Bytes(), nil
+		},
+	},
+	MIMECBOR: {
+		MIME: MIMECBOR,
+		Decode: func(r io.Reader, obj any) error {
+			return cborDec.NewDecoder(r).Decode(obj)
+		},
+		Encode: cborEnc.Marshal,
+	},
+}
+
+// Aliases clients commonly send for the binary formats.
+var codecAliases = map[string]string{
+	"application/x-msgpack":   MIMEMsgPack,
+	"application/vnd.msgpack": MIMEMsgPack,
+}
+
+const (
+	requestCodecKey  = "requestCodec"
+	responseCodecKey = "responseCodec"
+)
+
+func lookupCodec(mediaType string) (*Codec, bool) {
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	if alias, ok := codecAliases[mediaType]; ok {
+		mediaType = alias
+	}
+	codec, ok := Codecs[mediaType]
+	return codec, ok
+}
+
+// acceptedCodec walks the Accept header in preference order and returns the
+// first supported codec. A wildcard yields fallback.
+func acceptedCodec(accept string, fallback *Codec) (*Codec, bool) {
+	type candidate struct {
+		mediaType string
+		q         float64
+	}
+
+	var candidates []candidate
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		if q > 0 {
+			candidates = append(candidates, candidate{mediaType, q})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+
+	for _, cand := range candidates {
+		if cand.mediaType == "*/*" || cand.mediaType == "application/*" {
+			return fallback, true
+		}
+		if codec, ok := lookupCodec(cand.mediaType); ok {
+			return codec, true
+		}
+	}
+	return nil, false
+}
+
+// Negotiate picks the request and response codecs from Content-Type and
+// Accept. Responses default to the request's format, then to JSON, which
+// GET requests also fall back to when nothing in Accept is supported.
+func Negotiate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		reqCodec := Codecs[MIMEJSON]
+		if contentType := c.GetHeader("Content-Type"); contentType != "" {
+			mediaType, _, err := mime.ParseMediaType(contentType)
+			codec, ok := lookupCodec(mediaType)
+			if err != nil || !ok {
+				c.AbortWithStatusJSON(http.StatusUnsupportedMediaType, gin.H{"error": "Unsupported Content-Type"})
+				return
+			}
+			reqCodec = codec
+		}
+
+		resCodec := reqCodec
+		if accept := c.GetHeader("Accept"); accept != "" {
+			codec, ok := acceptedCodec(accept, reqCodec)
+			// Pages like / and /ping are opened in browsers, which ask for HTML.
+			if !ok && (c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead) {
+				codec, ok = Codecs[MIMEJSON], true
+			}
+			if !ok {
+				c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"error": "Not Acceptable"})
+				return
+			}
+			resCodec = codec
+		}
+
+		c.Set(requestCodecKey, reqCodec)
+		c.Set(responseCodecKey, resCodec)
+		c.Next()
+	}
+}
+
+func codecFrom(c *gin.Context, key string) *Codec {
+	if v, ok := c.Get(key); ok {
+		return v.(*Codec)
+	}
+	return Codecs[MIMEJSON]
+}
+
+// BindBody decodes the request body with the negotiated codec.
+func BindBody(c *gin.Context, obj any) error {
+	return codecFrom(c, requestCodecKey).Decode(c.Request.Body, obj)
+}
+
+// Respond encodes obj with the negotiated codec.
+func Respond(c *gin.Context, code int, obj any) {
+	codec := codecFrom(c, responseCodecKey)
+	if codec.MIME == MIMEJSON {
+		c.JSON(code, obj)
+		return
+	}
+
+	data, err := codec.Encode(obj)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode response"})
+		return
+	}
+	c.Data(code, codec.MIME, data)
+}
+
+// AbortRespond is Respond for handlers that stop the chain.
+func AbortRespond(c *gin.Context, code int, obj any) {
+	c.Abort()
+	Respond(c, code, obj)
+}
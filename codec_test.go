@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"hack/backend/models"
+	"hack/backend/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// negotiated serves a POST route that echoes its body and a GET page, both
+// behind Negotiate.
+func negotiated() http.Handler {
+	router := gin.New()
+	router.Use(Negotiate())
+	router.POST("/echo", func(c *gin.Context) {
+		var body map[string]interface{}
+		if err := BindBody(c, &body); err != nil {
+			Respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		Respond(c, http.StatusOK, body)
+	})
+	router.GET("/page", func(c *gin.Context) {
+		Respond(c, http.StatusOK, gin.H{"message": "ok"})
+	})
+	return router
+}
+
+func encode(t *testing.T, mime string, obj any) []byte {
+	t.Helper()
+	data, err := Codecs[mime].Encode(obj)
+	if err != nil {
+		t.Fatalf("failed to encode %s: %s", mime, err)
+	}
+	return data
+}
+
+func TestNegotiate(t *testing.T) {
+	const browser = "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,*/*;q=0.8"
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		accept      string
+		status      int
+		response    string // expected Content-Type, empty when aborted
+	}{
+		{"json", http.MethodPost, "/echo", MIMEJSON, "", http.StatusOK, MIMEJSON},
+		{"msgpack mirrors the request", http.MethodPost, "/echo", MIMEMsgPack, "", http.StatusOK, MIMEMsgPack},
+		{"cbor mirrors the request", http.MethodPost, "/echo", MIMECBOR, "", http.StatusOK, MIMECBOR},
+		{"msgpack alias", http.MethodPost, "/echo", "application/x-msgpack", "", http.StatusOK, MIMEMsgPack},
+		{"accept picks the response", http.MethodPost, "/echo", MIMEJSON, MIMECBOR, http.StatusOK, MIMECBOR},
+		{"accept quality order", http.MethodPost, "/echo", MIMEJSON, "application/cbor;q=0.5, application/msgpack", http.StatusOK, MIMEMsgPack},
+		{"wildcard mirrors the request", http.MethodPost, "/echo", MIMEMsgPack, "*/*", http.StatusOK, MIMEMsgPack},
+		{"application wildcard", http.MethodPost, "/echo", MIMECBOR, "application/*", http.StatusOK, MIMECBOR},
+		{"unsupported content type", http.MethodPost, "/echo", "text/xml", "", http.StatusUnsupportedMediaType, ""},
+		{"malformed content type", http.MethodPost, "/echo", "application/json; =", "", http.StatusUnsupportedMediaType, ""},
+		{"not acceptable on the api", http.MethodPost, "/echo", MIMEJSON, "text/html", http.StatusNotAcceptable, ""},
+		{"browser on a page", http.MethodGet, "/page", "", browser, http.StatusOK, MIMEJSON},
+		{"html only on a page", http.MethodGet, "/page", "", "text/html", http.StatusOK, MIMEJSON},
+		{"wildcard on a page", http.MethodGet, "/page", "", "*/*", http.StatusOK, MIMEJSON},
+	}
+
+	router := negotiated()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var body []byte
+			if test.method == http.MethodPost {
+				mime := MIMEJSON
+				if codec, ok := lookupCodec(test.contentType); ok {
+					mime = codec.MIME
+				}
+				body = encode(t, mime, map[string]interface{}{"name": "Ada"})
+			}
+
+			request := httptest.NewRequest(test.method, test.path, bytes.NewReader(body))
+			if test.contentType != "" {
+				request.Header.Set("Content-Type", test.contentType)
+			}
+			if test.accept != "" {
+				request.Header.Set("Accept", test.accept)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+
+			if recorder.Code != test.status {
+				t.Fatalf("expected status %d, got %d: %s", test.status, recorder.Code, recorder.Body.String())
+			}
+			if test.response == "" {
+				return
+			}
+			codec, ok := lookupCodec(test.response)
+			if contentType := recorder.Header().Get("Content-Type"); !ok || !strings.HasPrefix(contentType, test.response) {
+				t.Fatalf("expected %s response, got %q", test.response, contentType)
+			}
+
+			var decoded map[string]interface{}
+			if err := codec.Decode(recorder.Body, &decoded); err != nil {
+				t.Fatalf("failed to decode %s response: %s", test.response, err)
+			}
+			if test.method == http.MethodPost && decoded["name"] != "Ada" {
+				t.Fatalf("expected the body echoed, got %v", decoded)
+			}
+		})
+	}
+}
+
+// A browser opening the landing page gets JSON rather than 406.
+func TestLandingPageInBrowser(t *testing.T) {
+	previous := store
+	store = storage.NewMemory()
+	t.Cleanup(func() { store = previous })
+
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.Header.Set("Accept", "text/html,application/xhtml+xml")
+	recorder := httptest.NewRecorder()
+	Router().ServeHTTP(recorder, request)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if contentType := recorder.Header().Get("Content-Type"); !strings.HasPrefix(contentType, MIMEJSON) {
+		t.Fatalf("expected JSON, got %q", contentType)
+	}
+}
+
+// Dates sent as Unix milliseconds decode to whichever integer kind the
+// binary codecs pick, and all of them are accepted as timestamps.
+func TestCodecTimestamps(t *testing.T) {
+	field := &models.Field{Column: "expiresAt", Type: reflect.TypeOf(time.Time{})}
+	for _, mime := range []string{MIMEMsgPack, MIMECBOR} {
+		for _, millis := range []int64{1700000000000, 120000, 200, 7, -300000} {
+			var decoded map[string]interface{}
+			if err := Codecs[mime].Decode(bytes.NewReader(encode(t, mime, map[string]interface{}{"expiresAt": millis})), &decoded); err != nil {
+				t.Fatalf("failed to decode %s: %s", mime, err)
+			}
+			coerced, err := field.Coerce(decoded["expiresAt"])
+			if err != nil {
+				t.Errorf("%s %d decoded as %T: %s", mime, millis, decoded["expiresAt"], err)
+				continue
+			}
+			if expected := time.UnixMilli(millis).UTC(); !coerced.(time.Time).Equal(expected) {
+				t.Errorf("%s %d: expected %s, got %s", mime, millis, expected, coerced)
+			}
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"hack/backend/docs"
+	"hack/backend/migrations"
+	"hack/backend/models"
+	"hack/backend/schema"
+	u "hack/backend/utils"
+)
+
+func migrationRunner() *migrations.Runner {
+	return &migrations.Runner{DB: &db, Dialect: db.Dialect()}
+}
+
+// schemaDiff compares the registered models with the live database.
+func schemaDiff(ctx context.Context) ([]schema.Change, error) {
+	live, err := schema.Inspect(ctx, db.Dialect(), &db)
+	if err != nil {
+		return nil, err
+	}
+	return schema.Diff(db.Dialect(), models.All(), live)
+}
+
+// schemaReport annotates the registered models with the live database, or
+// only lists them when the database cannot be reached.
+func schemaReport(ctx context.Context) (bool, []schema.ModelReport, error) {
+	db.Connect()
+
+	var live map[string]*schema.Table
+	if db.IsConnected() {
+		var err error
+		live, err = schema.Inspect(ctx, db.Dialect(), &db)
+		if err != nil && !errors.Is(err, schema.ErrUnsupported) {
+			return true, nil, err
+		}
+	}
+	return db.IsConnected(), schema.Compare(db.Dialect(), models.All(), live), nil
+}
+
+// runMigrateCommand implements `backend migrate up|down [steps]|status|diff`.
+func runMigrateCommand(args []string) int {
+	usage := "usage: migrate up | down [steps] | status | diff"
+	if len(args) == 0 {
+		u.Error(usage)
+		return 2
+	}
+
+	db.Connect()
+	if !db.IsConnected() {
+		return 1
+	}
+	defer db.Close()
+
+	if err := loadDynamicModels(); err != nil {
+		u.ErrorF("Failed to load models from /create-schema:\t%s\n", err.Error())
+		return 1
+	}
+
+	ctx := context.Background()
+	runner := migrationRunner()
+
+	switch args[0] {
+	case "up":
+		applied, err := runner.Up(ctx)
+		for _, migration := range applied {
+			u.InfoF("Applied %d %s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			u.ErrorF("Migration failed:\t%s\n", err.Error())
+			return 1
+		}
+		if len(applied) == 0 {
+			u.Info("No pending migrations")
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			parsed, err := strconv.Atoi(args[1])
+			if err != nil || parsed < 1 {
+				u.Error(usage)
+				return 2
+			}
+			steps = parsed
+		}
+		reverted, err := runner.Down(ctx, steps)
+		for _, migration := range reverted {
+			u.InfoF("Reverted %d %s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			u.ErrorF("Migration failed:\t%s\n", err.Error())
+			return 1
+		}
+	case "status":
+		statuses, err := runner.Status(ctx)
+		if err != nil {
+			u.ErrorF("Failed to read migrations:\t%s\n", err.Error())
+			return 1
+		}
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%6d  %-40s %s\n", status.Version, status.Name, applied)
+		}
+	case "diff":
+		changes, err := schemaDiff(ctx)
+		if err != nil {
+			u.ErrorF("Failed to diff schema:\t%s\n", err.Error())
+			return 1
+		}
+		var statements []string
+		for _, change := range changes {
+			statements = append(statements, change.SQL)
+		}
+		if len(statements) > 0 {
+			fmt.Fprintln(os.Stdout, strings.Join(statements, "\n"))
+		}
+	default:
+		u.Error(usage)
+		return 2
+	}
+	return 0
+}
+
+// runGenerateModelsCommand implements `backend generate-models`, which
+// writes Go structs for existing tables.
+func runGenerateModelsCommand(args []string) int {
+	flags := flag.NewFlagSet("generate-models", flag.ContinueOnError)
+	tables := flags.String("tables", "", "comma separated tables to generate, default all")
+	out := flags.String("out", "", "file to write, default stdout")
+	packageName := flags.String("package", "main", "package of the generated file")
+	register := flags.Bool("register", false, "also generate an init function registering the models")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	db.Connect()
+	if !db.IsConnected() {
+		return 1
+	}
+	defer db.Close()
+
+	live, err := schema.Inspect(context.Background(), db.Dialect(), &db)
+	if err != nil {
+		u.ErrorF("Failed to inspect schema:\t%s\n", err.Error())
+		return 1
+	}
+
+	// Bookkeeping tables of this backend are never worth generating.
+	skip := map[string]bool{migrations.Table: true}
+	for _, model := range models.All() {
+		if model.Options.Internal {
+			skip[model.Table] = true
+		}
+	}
+
+	var selected []*schema.Table
+	if *tables == "" {
+		for name, table := range live {
+			if !skip[name] {
+				selected = append(selected, table)
+			}
+		}
+	} else {
+		for _, name := range strings.Split(*tables, ",") {
+			table, ok := live[strings.TrimSpace(name)]
+			if !ok {
+				u.ErrorF("Table %s does not exist\n", name)
+				return 1
+			}
+			selected = append(selected, table)
+		}
+	}
+
+	source, err := schema.GenerateGo(selected, schema.GenerateOptions{Package: *packageName, Register: *register})
+	if err != nil {
+		u.ErrorF("Failed to generate models:\t%s\n", err.Error())
+		return 1
+	}
+
+	if *out == "" {
+		os.Stdout.Write(source)
+		return 0
+	}
+	if err := os.WriteFile(*out, source, 0644); err != nil {
+		u.ErrorF("Failed to write %s:\t%s\n", *out, err.Error())
+		return 1
+	}
+	u.InfoF("Wrote %d models to %s\n", len(selected), *out)
+	return 0
+}
+
+// runDocsCommand implements `backend docs`, which renders the registered
+// models as Markdown with a Mermaid ER diagram.
+func runDocsCommand(args []string) int {
+	flags := flag.NewFlagSet("docs", flag.ContinueOnError)
+	out := flags.String("out", "", "file to write, default stdout")
+	format := flags.String("format", "markdown", "markdown or mermaid")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	documented := docs.Documented(models.All())
+
+	var output string
+	switch *format {
+	case "markdown":
+		output = docs.Markdown(db.Dialect(), documented)
+	case "mermaid":
+		output = docs.Mermaid(db.Dialect(), documented)
+	default:
+		u.ErrorF("Unknown format %s\n", *format)
+		return 2
+	}
+
+	if *out == "" {
+		fmt.Print(output)
+		return 0
+	}
+	if err := os.WriteFile(*out, []byte(output), 0644); err != nil {
+		u.ErrorF("Failed to write %s:\t%s\n", *out, err.Error())
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,15 @@
+package main
+
+// allowedOrigin is the Access-Control-Allow-Origin to answer a request from
+// origin with, or "" when the origin is not allowed.
+func allowedOrigin(origin string) string {
+	for _, allowed := range settings.CORS.AllowOrigins {
+		if allowed == "*" {
+			return "*"
+		}
+		if origin != "" && origin == allowed {
+			return origin
+		}
+	}
+	return ""
+}
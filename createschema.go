@@ -0,0 +1,226 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"hack/backend/dialect"
+	"hack/backend/models"
+	s "hack/backend/server"
+)
+
+// schemaPlan is what a /create-schema payload does to the registry and the
+// database.
+type schemaPlan struct {
+	Models     []*models.Model
+	Statements []string
+}
+
+// planSchema turns Better Auth tables into models and the DDL that creates
+// them. Tables that already exist as models only gain their missing fields,
+// so core tables sent along with plugin tables are left as they are.
+func planSchema(tables map[string]models.BetterAuthTable) (*schemaPlan, error) {
+	names := map[string]string{}
+	for key, table := range tables {
+		names[key] = key
+		if table.ModelName != "" {
+			names[key] = table.ModelName
+		}
+	}
+	planned := map[string]bool{}
+	for _, name := range names {
+		planned[name] = true
+	}
+
+	d := db.Dialect()
+	plan := &schemaPlan{}
+
+	for _, key := range models.SortBetterAuthTables(tables) {
+		table := tables[key]
+		model, err := models.FromBetterAuth(key, table, names)
+		if err != nil {
+			return nil, err
+		}
+		if err := model.Validate(); err != nil {
+			return nil, err
+		}
+		for _, field := range model.Fields {
+			if ref := field.References; ref != nil && !planned[ref.Model] {
+				if _, ok := models.Lookup(ref.Model); !ok {
+					return nil, fmt.Errorf("field '%s' of '%s' references unknown model '%s'", field.Column, key, ref.Model)
+				}
+			}
+		}
+
+		existing, ok := models.Lookup(model.Name)
+		if ok && existing.Options.Internal {
+			return nil, fmt.Errorf("model name '%s' is reserved", model.Name)
+		}
+
+		if ok {
+			extended, added := existing.Extend(model)
+			plan.Models = append(plan.Models, extended)
+			if table.DisableMigrations {
+				continue
+			}
+			for _, field := range added {
+				plan.Statements = append(plan.Statements, fmt.Sprintf(
+					"ALTER TABLE %s ADD COLUMN%s %s;", extended.QuotedTable(), ifNotExists(d), models.ColumnDefinition(d, extended, field),
+				))
+				if field.References != nil && !d.SupportsInlineReferences() {
+					plan.Statements = append(plan.Statements, extended.ForeignKeyStatement(field))
+				}
+				if field.Index {
+					plan.Statements = append(plan.Statements, extended.IndexStatement(d, field))
+				}
+				if field.Unique && extended.Options.SoftDelete {
+					plan.Statements = append(plan.Statements, extended.UniqueIndexStatement(d, field.Column))
+				}
+			}
+			continue
+		}
+
+		plan.Models = append(plan.Models, model)
+		if table.DisableMigrations {
+			continue
+		}
+		query, err := models.GenerateCreateTableSQL(d, model)
+		if err != nil {
+			return nil, err
+		}
+		plan.Statements = append(plan.Statements, query)
+		plan.Statements = append(plan.Statements, models.GenerateIndexSQL(d, model)...)
+	}
+
+	return plan, nil
+}
+
+// ifNotExists guards ADD COLUMN where the dialect allows it. SQLite has no
+// such guard.
+func ifNotExists(d dialect.Dialect) string {
+	if d.Name() == "postgres" {
+		return " IF NOT EXISTS"
+	}
+	return ""
+}
+
+// applySchema runs the plan in one transaction and stores the table
+// definitions for the next startup.
+func applySchema(tables map[string]models.BetterAuthTable, plan *schemaPlan) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	dynamic, _ := models.Lookup("dynamicmodel")
+	statements, err := schemaStatements(dynamic)
+	if err != nil {
+		return err
+	}
+	for _, statement := range append(statements, plan.Statements...) {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	for key, table := range tables {
+		if err := saveDynamicModel(tx, key, table); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// registerSchema makes the adapter serve the planned models.
+func registerSchema(plan *schemaPlan) error {
+	for _, model := range plan.Models {
+		if err := models.Add(model); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveDynamicModel stores a table definition, keeping fields from earlier
+// definitions since their columns stay in the database.
+func saveDynamicModel(tx *s.Tx, key string, table models.BetterAuthTable) error {
+	fields := map[string]models.BetterAuthField{}
+
+	var stored string
+	err := tx.QueryRow(`SELECT "definition" FROM "dynamicmodel" WHERE "key" = ?`, key).Scan(&stored)
+	switch {
+	case err == nil:
+		var previous models.BetterAuthTable
+		if err := json.Unmarshal([]byte(stored), &previous); err != nil {
+			return err
+		}
+		for name, field := range previous.Fields {
+			fields[name] = field
+		}
+	case err != sql.ErrNoRows:
+		return err
+	}
+
+	for name, field := range table.Fields {
+		fields[name] = field
+	}
+	table.Fields = fields
+
+	definition, err := json.Marshal(table)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO "dynamicmodel" ("key", "definition", "updatedAt") VALUES (?, ?, ?) `+tx.Dialect.Upsert([]string{"key"}, []string{"definition", "updatedAt"}),
+		key, string(definition), time.Now(),
+	)
+	return err
+}
+
+// loadDynamicModels registers the models created through /create-schema in
+// earlier runs.
+func loadDynamicModels() error {
+	dynamic, _ := models.Lookup("dynamicmodel")
+	statements, err := schemaStatements(dynamic)
+	if err != nil {
+		return err
+	}
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	rows, err := db.Query(`SELECT "key", "definition" FROM "dynamicmodel"`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	tables := map[string]models.BetterAuthTable{}
+	for rows.Next() {
+		var key, definition string
+		if err := rows.Scan(&key, &definition); err != nil {
+			return err
+		}
+		var table models.BetterAuthTable
+		if err := json.Unmarshal([]byte(definition), &table); err != nil {
+			return fmt.Errorf("invalid stored definition for '%s': %w", key, err)
+		}
+		tables[key] = table
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	plan, err := planSchema(tables)
+	if err != nil {
+		return err
+	}
+	return registerSchema(plan)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	s "hack/backend/server"
+	"hack/backend/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireDatabase fails adapter calls fast with 503 while the database is
+// down and reconnecting in the background, instead of letting each one
+// wait on a connection attempt.
+func RequireDatabase() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := store.(*storage.SQL); !ok {
+			c.Next()
+			return
+		}
+
+		db.Connect()
+		if retryAfter, down := db.Unavailable(); down {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			AbortRespond(c, http.StatusServiceUnavailable, gin.H{"error": "Database unavailable"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// writeRoutes are the adapter calls after which a client reads from the
+// primary for a while, see ReadYourWrites.
+var writeRoutes = map[string]bool{
+	"/create":        true,
+	"/update":        true,
+	"/update-many":   true,
+	"/delete":        true,
+	"/delete-many":   true,
+	"/transaction":   true,
+	"/create-schema": true,
+}
+
+// lastWrites holds when each client last wrote, swept every minute.
+var (
+	lastWrites sync.Map
+	lastSweep  atomic.Int64
+)
+
+// ReadYourWrites pins a client's reads to the primary for window after it
+// writes, so a lagging read replica cannot hide the write from it. Clients
+// are told apart by the X-Client-ID header; without it a client is never
+// pinned, since clients behind one proxy share an address.
+func ReadYourWrites(window time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		client := c.GetHeader("X-Client-ID")
+		if window <= 0 || client == "" {
+			c.Next()
+			return
+		}
+
+		if wrote, ok := lastWrites.Load(client); ok && time.Since(wrote.(time.Time)) < window {
+			c.Request = c.Request.WithContext(s.WithPrimary(c.Request.Context()))
+		}
+
+		c.Next()
+
+		if !writeRoutes[c.FullPath()] || c.Writer.Status() >= http.StatusBadRequest {
+			return
+		}
+		now := time.Now()
+		lastWrites.Store(client, now)
+		if swept := lastSweep.Load(); now.Unix()-swept >= 60 && lastSweep.CompareAndSwap(swept, now.Unix()) {
+			lastWrites.Range(func(client, wrote any) bool {
+				if now.Sub(wrote.(time.Time)) >= window {
+					lastWrites.Delete(client)
+				}
+				return true
+			})
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	s "hack/backend/server"
+	"hack/backend/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+func requireDatabaseRouter() *gin.Engine {
+	router := gin.New()
+	router.POST("/find-one", RequireDatabase(), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	})
+	return router
+}
+
+// While the database is down adapter calls fail fast with 503 and a
+// Retry-After.
+func TestRequireDatabase(t *testing.T) {
+	previousStore, previousBackoff := store, s.ReconnectBackoff
+	// Keeps the background reconnect from retrying during the test.
+	s.ReconnectBackoff = s.Backoff{Initial: time.Hour, Max: time.Hour}
+	t.Cleanup(func() {
+		db.Close()
+		store, s.ReconnectBackoff = previousStore, previousBackoff
+	})
+
+	db.Close()
+	db.Configure(s.DatabaseConfig{Driver: s.Driver(s.SQLITE), Database: filepath.Join(t.TempDir(), "missing", "app.db")})
+	store = storage.NewSQL(&db)
+
+	previous := db.Breaker().NextAttempt
+	db.Connect()
+	// Scheduled by the background reconnect, which has read the backoff.
+	for db.Breaker().NextAttempt.Equal(previous) {
+		time.Sleep(time.Millisecond)
+	}
+
+	recorder := httptest.NewRecorder()
+	requireDatabaseRouter().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/find-one", nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status 503, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	retryAfter, err := strconv.Atoi(recorder.Header().Get("Retry-After"))
+	if err != nil || retryAfter < 1800 || retryAfter > 3600 {
+		t.Errorf("expected Retry-After to be the next attempt in seconds, got %q", recorder.Header().Get("Retry-After"))
+	}
+	if status := db.Breaker(); status.State == s.BreakerClosed {
+		t.Errorf("expected the breaker to be open, got %+v", status)
+	}
+
+	// The memory storage needs no database.
+	store = storage.NewMemory()
+	recorder = httptest.NewRecorder()
+	requireDatabaseRouter().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/find-one", nil))
+	if recorder.Code != http.StatusOK {
+		t.Errorf("expected status 200 on the memory storage, got %d", recorder.Code)
+	}
+}
+
+// After a client writes, its reads go to the primary for the window, while
+// other clients keep reading from the replicas.
+func TestReadYourWrites(t *testing.T) {
+	t.Cleanup(db.Close)
+	db.Close()
+	primary := s.DatabaseConfig{Driver: s.Driver(s.SQLITE), Database: ":memory:"}
+	primary.Replicas = []s.DatabaseConfig{primary}
+	if err := db.Reconfigure(context.Background(), primary); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); len(db.Replicas()) == 0 || !db.Replicas()[0].Healthy; {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the replica to become healthy")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	window := 200 * time.Millisecond
+	router := gin.New()
+	router.Use(ReadYourWrites(window))
+	router.POST("/create", func(c *gin.Context) {
+		if c.Query("fail") != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{})
+	})
+	router.POST("/find-one", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"primary": db.Reader(c.Request.Context()) == &db})
+	})
+
+	call := func(path string, client string) bool {
+		t.Helper()
+		request := httptest.NewRequest(http.MethodPost, path, nil)
+		if client != "" {
+			request.Header.Set("X-Client-ID", client)
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		return strings.Contains(recorder.Body.String(), `"primary":true`)
+	}
+
+	writer, other := "writer-"+t.Name(), "other-"+t.Name()
+	if call("/find-one", writer) {
+		t.Error("expected reads to go to the replica before writing")
+	}
+	call("/create?fail=1", writer)
+	if call("/find-one", writer) {
+		t.Error("expected a failed write not to pin reads")
+	}
+
+	call("/create", writer)
+	if !call("/find-one", writer) {
+		t.Error("expected reads to go to the primary right after writing")
+	}
+	if call("/find-one", other) {
+		t.Error("expected other clients to keep reading from the replica")
+	}
+
+	time.Sleep(window)
+	if call("/find-one", writer) {
+		t.Error("expected reads to go back to the replica after the window")
+	}
+
+	// Clients behind one proxy share an address, so one without an ID is
+	// never pinned and does not pin the others.
+	call("/create", "")
+	if call("/find-one", "") {
+		t.Error("expected a client without X-Client-ID not to be pinned")
+	}
+}
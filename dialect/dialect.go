@@ -0,0 +1,97 @@
+package dialect
+
+import (
+	"reflect"
+	"sync"
+)
+
+// Dialect captures what differs between the SQL databases the backend can
+// talk to.
+//
+// Queries are written once in a canonical form, with ? placeholders and
+// double-quoted identifiers, and translated with Rebind just before they
+// are executed.
+type Dialect interface {
+	// Name identifies the dialect, e.g. "postgres".
+	Name() string
+	// ColumnType maps a Go value type (pointers and sql.Null wrappers already
+	// removed) to a column type.
+	ColumnType(t reflect.Type) string
+	// Placeholder is the bind parameter for the n-th argument, counting from 1.
+	Placeholder(n int) string
+	// QuoteIdent quotes a table or column name.
+	QuoteIdent(name string) string
+	// SupportsReturning reports whether INSERT, UPDATE and DELETE accept a
+	// RETURNING clause.
+	SupportsReturning() bool
+	// SupportsIndexIfNotExists reports whether CREATE INDEX accepts IF NOT
+	// EXISTS. Without it indexes are declared inside CREATE TABLE.
+	SupportsIndexIfNotExists() bool
+	// SupportsInlineReferences reports whether REFERENCES is honored in a
+	// column definition. Without it foreign keys are table constraints.
+	SupportsInlineReferences() bool
+	// SupportsPartialIndex reports whether CREATE INDEX accepts a WHERE
+	// clause. Without it an index limited to some rows keys on an
+	// expression that is NULL for the others.
+	SupportsPartialIndex() bool
+	// AutoIncrement is the column clause that numbers new rows of an
+	// integer primary key, empty when the database does so by itself.
+	AutoIncrement() string
+	// Upsert is the clause, in canonical SQL, appended to an INSERT so that
+	// a row colliding on the conflict columns updates the update columns
+	// from the new row instead, or is skipped when update is empty.
+	Upsert(conflict []string, update []string) string
+	// Concat joins string expressions.
+	Concat(parts ...string) string
+	// Lock returns the statements that take and release a named lock for
+	// the session, or empty strings when the database needs none.
+	Lock(name string) (acquire string, release string)
+	// Arg converts a query argument into a value the driver can bind.
+	Arg(value interface{}) interface{}
+}
+
+var registry = struct {
+	sync.RWMutex
+	byName   map[string]Dialect
+	byDriver map[string]Dialect
+}{byName: map[string]Dialect{}, byDriver: map[string]Dialect{}}
+
+// ColumnAdjuster is implemented by dialects that change a column's type or
+// default by how the column is used. keyed is true for primary key, unique,
+// indexed and referencing columns.
+type ColumnAdjuster interface {
+	AdjustColumn(t reflect.Type, columnType string, def string, keyed bool) (string, string)
+}
+
+// Register makes a dialect available by name and for the given database/sql
+// driver names.
+func Register(d Dialect, drivers ...string) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	registry.byName[d.Name()] = d
+	for _, driver := range drivers {
+		registry.byDriver[driver] = d
+	}
+}
+
+// Get finds a dialect by name.
+func Get(name string) (Dialect, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	d, ok := registry.byName[name]
+	return d, ok
+}
+
+// ForDriver finds the dialect spoken by a database/sql driver, falling back
+// to Postgres.
+func ForDriver(driver string) Dialect {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	if d, ok := registry.byDriver[driver]; ok {
+		return d
+	}
+	return Postgres
+}
@@ -0,0 +1,130 @@
+package dialect
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+type mysql struct{}
+
+var MySQL Dialect = mysql{}
+
+func init() {
+	Register(MySQL, "mysql")
+}
+
+func (mysql) Name() string {
+	return "mysql"
+}
+
+func (mysql) ColumnType(t reflect.Type) string {
+	switch classify(t) {
+	case classTime:
+		return "DATETIME(3)"
+	case classBytes:
+		return "LONGBLOB"
+	case classJSON, classArray:
+		return "JSON"
+	case classUUID:
+		return "CHAR(36)"
+	case classDecimal:
+		return "DECIMAL(65,30)"
+	}
+
+	switch t.Kind() {
+	case reflect.Int8, reflect.Uint8:
+		return "TINYINT"
+	case reflect.Int16, reflect.Uint16:
+		return "SMALLINT"
+	case reflect.Int32, reflect.Uint32:
+		return "INT"
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return "BIGINT"
+	case reflect.Bool:
+		return "BOOLEAN"
+	case reflect.Float32:
+		return "FLOAT"
+	case reflect.Float64:
+		return "DOUBLE"
+	}
+	return "TEXT"
+}
+
+// AdjustColumn bounds TEXT columns that are keyed or have a default, which
+// MySQL only allows on VARCHAR. 191 characters keep an utf8mb4 key within
+// the smallest InnoDB key prefix. CURRENT_TIMESTAMP needs the precision of
+// the DATETIME column.
+func (mysql) AdjustColumn(t reflect.Type, columnType string, def string, keyed bool) (string, string) {
+	if columnType == "TEXT" && (keyed || def != "") {
+		columnType = "VARCHAR(191)"
+	}
+	if strings.HasPrefix(columnType, "DATETIME(") && strings.EqualFold(def, "CURRENT_TIMESTAMP") {
+		def = "CURRENT_TIMESTAMP" + strings.TrimPrefix(columnType, "DATETIME")
+	}
+	return columnType, def
+}
+
+func (mysql) Placeholder(n int) string {
+	return "?"
+}
+
+func (mysql) QuoteIdent(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// SupportsReturning is false, inserted rows are read back by primary key.
+func (mysql) SupportsReturning() bool {
+	return false
+}
+
+func (mysql) SupportsIndexIfNotExists() bool {
+	return false
+}
+
+// SupportsInlineReferences is false, InnoDB parses and then ignores
+// REFERENCES in a column definition.
+func (mysql) SupportsInlineReferences() bool {
+	return false
+}
+
+// SupportsPartialIndex is false, functional key parts (MySQL 8.0.13, not
+// MariaDB) stand in.
+func (mysql) SupportsPartialIndex() bool {
+	return false
+}
+
+func (mysql) AutoIncrement() string {
+	return "AUTO_INCREMENT"
+}
+
+// Upsert uses ON DUPLICATE KEY UPDATE, which fires on any unique key, not
+// only the conflict columns. Skipping is a no-op assignment, so the row
+// counts as unaffected.
+func (mysql) Upsert(conflict []string, update []string) string {
+	if len(update) == 0 {
+		column := quoteANSI(conflict[0])
+		return "ON DUPLICATE KEY UPDATE " + column + " = " + column
+	}
+
+	sets := make([]string, len(update))
+	for i, column := range update {
+		sets[i] = fmt.Sprintf("%s = VALUES(%s)", quoteANSI(column), quoteANSI(column))
+	}
+	return "ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", ")
+}
+
+// Concat uses CONCAT, || is a logical OR unless PIPES_AS_CONCAT is set.
+func (mysql) Concat(parts ...string) string {
+	return "CONCAT(" + strings.Join(parts, ", ") + ")"
+}
+
+func (mysql) Lock(name string) (string, string) {
+	literal := "'" + strings.ReplaceAll(name, "'", "''") + "'"
+	return "SELECT GET_LOCK(" + literal + ", -1)", "SELECT RELEASE_LOCK(" + literal + ")"
+}
+
+// Arg encodes slices and maps as JSON, MySQL has no array types.
+func (mysql) Arg(value interface{}) interface{} {
+	return jsonArg(value)
+}
@@ -0,0 +1,99 @@
+package dialect
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+type postgres struct{}
+
+var Postgres Dialect = postgres{}
+
+func init() {
+	Register(Postgres, "pgx", "postgres")
+}
+
+func (postgres) Name() string {
+	return "postgres"
+}
+
+func (this postgres) ColumnType(t reflect.Type) string {
+	switch classify(t) {
+	case classTime:
+		return "TIMESTAMPTZ"
+	case classBytes:
+		return "BYTEA"
+	case classJSON:
+		return "JSONB"
+	case classUUID:
+		return "UUID"
+	case classDecimal:
+		return "NUMERIC"
+	case classArray:
+		return this.ColumnType(t.Elem()) + "[]"
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return "TEXT"
+	case reflect.Int8, reflect.Int16, reflect.Uint8:
+		return "SMALLINT"
+	case reflect.Int32, reflect.Uint16, reflect.Int:
+		return "INTEGER"
+	case reflect.Int64, reflect.Uint32, reflect.Uint, reflect.Uint64:
+		return "BIGINT"
+	case reflect.Bool:
+		return "BOOLEAN"
+	case reflect.Float32:
+		return "REAL"
+	case reflect.Float64:
+		return "DOUBLE PRECISION"
+	}
+	return "TEXT" // Default to TEXT for other types
+}
+
+func (postgres) Placeholder(n int) string {
+	return fmt.Sprintf("$%d", n)
+}
+
+func (postgres) QuoteIdent(name string) string {
+	return quoteANSI(name)
+}
+
+func (postgres) SupportsReturning() bool {
+	return true
+}
+
+func (postgres) SupportsIndexIfNotExists() bool {
+	return true
+}
+
+func (postgres) SupportsInlineReferences() bool {
+	return true
+}
+
+func (postgres) SupportsPartialIndex() bool {
+	return true
+}
+
+func (postgres) AutoIncrement() string {
+	return "GENERATED BY DEFAULT AS IDENTITY"
+}
+
+func (postgres) Upsert(conflict []string, update []string) string {
+	return onConflict(conflict, update)
+}
+
+func (postgres) Concat(parts ...string) string {
+	return strings.Join(parts, " || ")
+}
+
+func (postgres) Lock(name string) (string, string) {
+	literal := "'" + strings.ReplaceAll(name, "'", "''") + "'"
+	return "SELECT pg_advisory_lock(hashtext(" + literal + "))", "SELECT pg_advisory_unlock(hashtext(" + literal + "))"
+}
+
+func (postgres) Arg(value interface{}) interface{} {
+	return value
+}
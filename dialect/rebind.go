@@ -0,0 +1,121 @@
+package dialect
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// Rebind translates a canonical query, with ? placeholders and double-quoted
+// identifiers, into the syntax of dialect d. String literals and comments
+// are copied as they are.
+func Rebind(d Dialect, query string) string {
+	var b strings.Builder
+	b.Grow(len(query) + 16)
+	n := 0
+
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+		switch {
+		case ch == '\'':
+			end := literalEnd(query, i, '\'')
+			b.WriteString(query[i:end])
+			i = end - 1
+		case ch == '"':
+			end := literalEnd(query, i, '"')
+			name := strings.ReplaceAll(strings.TrimSuffix(query[i+1:end], `"`), `""`, `"`)
+			b.WriteString(d.QuoteIdent(name))
+			i = end - 1
+		case ch == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			b.WriteString(query[i : i+end])
+			i += end - 1
+		case ch == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				end = len(query) - i - 4
+			}
+			b.WriteString(query[i : i+end+4])
+			i += end + 3
+		case ch == '?':
+			n++
+			b.WriteString(d.Placeholder(n))
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	return b.String()
+}
+
+// literalEnd finds the index just past the quoted section starting at
+// start, where a doubled quote is an escaped quote.
+func literalEnd(query string, start int, quote byte) int {
+	for i := start + 1; i < len(query); i++ {
+		if query[i] != quote {
+			continue
+		}
+		if i+1 < len(query) && query[i+1] == quote {
+			i++
+			continue
+		}
+		return i + 1
+	}
+	return len(query)
+}
+
+// Args converts query arguments with d.Arg.
+func Args(d Dialect, args []interface{}) []interface{} {
+	converted := make([]interface{}, len(args))
+	for i, arg := range args {
+		converted[i] = d.Arg(arg)
+	}
+	return converted
+}
+
+// jsonArg encodes slices other than []byte and maps as JSON text, for
+// databases without array types.
+func jsonArg(value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	switch reflect.TypeOf(value).Kind() {
+	case reflect.Slice:
+		if _, ok := value.([]byte); ok {
+			return value
+		}
+	case reflect.Map:
+	default:
+		return value
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	return string(encoded)
+}
+
+// quoteANSI quotes an identifier with double quotes, as Postgres and SQLite do.
+func quoteANSI(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// onConflict is the ON CONFLICT clause shared by Postgres and SQLite.
+func onConflict(conflict []string, update []string) string {
+	quoted := make([]string, len(conflict))
+	for i, column := range conflict {
+		quoted[i] = quoteANSI(column)
+	}
+	clause := "ON CONFLICT (" + strings.Join(quoted, ", ") + ")"
+	if len(update) == 0 {
+		return clause + " DO NOTHING"
+	}
+
+	sets := make([]string, len(update))
+	for i, column := range update {
+		sets[i] = quoteANSI(column) + " = EXCLUDED." + quoteANSI(column)
+	}
+	return clause + " DO UPDATE SET " + strings.Join(sets, ", ")
+}
@@ -0,0 +1,102 @@
+package dialect
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+type sqlite struct{}
+
+var SQLite Dialect = sqlite{}
+
+func init() {
+	Register(SQLite, "sqlite")
+}
+
+func (sqlite) Name() string {
+	return "sqlite"
+}
+
+// ColumnType uses the declared types the driver recognizes, so booleans and
+// timestamps come back typed. Arrays and JSON are stored as JSON text.
+func (sqlite) ColumnType(t reflect.Type) string {
+	switch classify(t) {
+	case classTime:
+		return "TIMESTAMP"
+	case classBytes:
+		return "BLOB"
+	case classJSON, classArray:
+		return "JSON"
+	case classUUID:
+		return "TEXT"
+	case classDecimal:
+		return "NUMERIC"
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "INTEGER"
+	case reflect.Bool:
+		return "BOOLEAN"
+	case reflect.Float32, reflect.Float64:
+		return "REAL"
+	}
+	return "TEXT"
+}
+
+func (sqlite) Placeholder(n int) string {
+	return "?"
+}
+
+func (sqlite) QuoteIdent(name string) string {
+	return quoteANSI(name)
+}
+
+func (sqlite) SupportsReturning() bool {
+	return true
+}
+
+func (sqlite) SupportsIndexIfNotExists() bool {
+	return true
+}
+
+func (sqlite) SupportsInlineReferences() bool {
+	return true
+}
+
+func (sqlite) SupportsPartialIndex() bool {
+	return true
+}
+
+// AutoIncrement is empty, an INTEGER primary key is the rowid.
+func (sqlite) AutoIncrement() string {
+	return ""
+}
+
+func (sqlite) Upsert(conflict []string, update []string) string {
+	return onConflict(conflict, update)
+}
+
+func (sqlite) Concat(parts ...string) string {
+	return strings.Join(parts, " || ")
+}
+
+// Lock is a no-op, SQLite serializes writers on the database file.
+func (sqlite) Lock(name string) (string, string) {
+	return "", ""
+}
+
+// sqliteTime is how timestamps are stored: in UTC and in the format of
+// CURRENT_TIMESTAMP, so stored text compares and sorts as time.
+const sqliteTime = "2006-01-02 15:04:05.999999999"
+
+// Arg encodes slices and maps as JSON, SQLite has no array or object types,
+// and timestamps as UTC text.
+func (sqlite) Arg(value interface{}) interface{} {
+	if t, ok := value.(time.Time); ok {
+		return t.UTC().Format(sqliteTime)
+	}
+	return jsonArg(value)
+}
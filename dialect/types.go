@@ -0,0 +1,53 @@
+package dialect
+
+import (
+	"encoding/json"
+	"reflect"
+	"time"
+)
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	jsonType = reflect.TypeOf(json.RawMessage{})
+)
+
+// Kinds of values that need more than their reflect.Kind to map to SQL.
+type typeClass int
+
+const (
+	classScalar typeClass = iota
+	classTime
+	classBytes
+	classJSON
+	classUUID
+	classDecimal
+	classArray
+)
+
+// classify groups Go types the same way for every dialect.
+func classify(t reflect.Type) typeClass {
+	switch {
+	case t == timeType:
+		return classTime
+	case t == jsonType:
+		return classJSON
+	case t.Name() == "UUID" && t.Kind() == reflect.Array && t.Len() == 16:
+		return classUUID
+	case t.Name() == "Decimal" || (t.PkgPath() == "math/big" && (t.Name() == "Int" || t.Name() == "Float" || t.Name() == "Rat")):
+		return classDecimal
+	}
+
+	switch t.Kind() {
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return classBytes
+		}
+		if classify(t.Elem()) == classJSON {
+			return classJSON
+		}
+		return classArray
+	case reflect.Map, reflect.Struct, reflect.Interface, reflect.Array:
+		return classJSON
+	}
+	return classScalar
+}
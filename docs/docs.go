@@ -0,0 +1,185 @@
+package docs
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"hack/backend/dialect"
+	"hack/backend/models"
+)
+
+// Documented leaves out internal models, which are not part of the schema
+// Better Auth sees.
+func Documented(registered []*models.Model) []*models.Model {
+	var documented []*models.Model
+	for _, model := range registered {
+		if !model.Options.Internal {
+			documented = append(documented, model)
+		}
+	}
+	return documented
+}
+
+// Keys lists the key markers of a field: PK, FK and UK.
+func Keys(field *models.Field) []string {
+	var keys []string
+	if field.PrimaryKey {
+		keys = append(keys, "PK")
+	}
+	if field.References != nil {
+		keys = append(keys, "FK")
+	}
+	if field.Unique && !field.PrimaryKey {
+		keys = append(keys, "UK")
+	}
+	return keys
+}
+
+var mermaidUnsafe = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// mermaidType turns a column type into a single Mermaid word.
+func mermaidType(sqlType string) string {
+	sqlType = strings.ReplaceAll(sqlType, "[]", "_array")
+	return strings.Trim(mermaidUnsafe.ReplaceAllString(sqlType, "_"), "_")
+}
+
+// Mermaid renders the models as a Mermaid ER diagram.
+func Mermaid(d dialect.Dialect, registered []*models.Model) string {
+	var b strings.Builder
+	b.WriteString("erDiagram\n")
+
+	for _, model := range registered {
+		fmt.Fprintf(&b, "    %s {\n", model.Table)
+		for _, field := range model.Fields {
+			fmt.Fprintf(&b, "        %s %s", mermaidType(field.ColumnType(d)), field.Column)
+			if keys := Keys(field); len(keys) > 0 {
+				b.WriteString(" " + strings.Join(keys, ", "))
+			}
+			if field.Nullable {
+				b.WriteString(` "nullable"`)
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("    }\n")
+	}
+
+	for _, model := range registered {
+		for _, field := range model.Fields {
+			ref := field.References
+			if ref == nil {
+				continue
+			}
+			// The referenced side is exactly one row, or at most one when the
+			// foreign key is nullable; the referencing side is many, or at
+			// most one when the foreign key is unique.
+			left, right := "||", "o{"
+			if field.Nullable {
+				left = "|o"
+			}
+			if field.Unique {
+				right = "o|"
+			}
+			fmt.Fprintf(&b, "    %s %s--%s %s : %q\n", ref.Table(), left, right, model.Table, field.Column)
+		}
+	}
+
+	return b.String()
+}
+
+// Row is one documented field.
+type Row struct {
+	Column      string
+	Type        string
+	Key         string
+	Nullable    bool
+	Default     string
+	Description string
+}
+
+// Table is one documented model.
+type Table struct {
+	Name       string
+	Table      string
+	SoftDelete bool
+	Rows       []Row
+	// UniqueTogether lists the columns of each composite unique constraint.
+	UniqueTogether [][]string
+}
+
+// Tables describes the models in the shape both the Markdown and the admin
+// page render.
+func Tables(d dialect.Dialect, registered []*models.Model) []Table {
+	tables := make([]Table, 0, len(registered))
+	for _, model := range registered {
+		table := Table{Name: model.Name, Table: model.Table, SoftDelete: model.Options.SoftDelete}
+
+		for _, field := range model.Fields {
+			key := strings.Join(Keys(field), ", ")
+			if ref := field.References; ref != nil {
+				key += fmt.Sprintf(" → %s.%s", ref.Table(), ref.Column)
+				if ref.OnDelete != "" {
+					key += " (on delete " + ref.OnDelete + ")"
+				}
+			}
+			if field.Index {
+				key = strings.TrimSpace(key + " indexed")
+			}
+			table.Rows = append(table.Rows, Row{
+				Column:      field.Column,
+				Type:        field.ColumnType(d),
+				Key:         key,
+				Nullable:    field.Nullable,
+				Default:     field.Default,
+				Description: field.Description,
+			})
+		}
+
+		names, groups := model.UniqueGroups()
+		for _, name := range names {
+			table.UniqueTogether = append(table.UniqueTogether, groups[name])
+		}
+		tables = append(tables, table)
+	}
+	return tables
+}
+
+func cell(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "|", `\|`), "\n", " ")
+}
+
+// Markdown renders the models as Markdown tables, preceded by the ER diagram.
+func Markdown(d dialect.Dialect, registered []*models.Model) string {
+	var b strings.Builder
+	b.WriteString("# Schema\n\n")
+	b.WriteString("Generated from the model registry with `go run . docs`, do not edit by hand.\n\n")
+	b.WriteString("```mermaid\n" + Mermaid(d, registered) + "```\n")
+
+	for _, table := range Tables(d, registered) {
+		fmt.Fprintf(&b, "\n## %s\n\nTable name: `%s`", table.Name, table.Table)
+		if table.SoftDelete {
+			b.WriteString(" (soft delete)")
+		}
+		b.WriteString("\n\n| Field | Type | Key | Nullable | Default | Description |\n")
+		b.WriteString("| --- | --- | --- | --- | --- | --- |\n")
+
+		for _, row := range table.Rows {
+			nullable := "no"
+			if row.Nullable {
+				nullable = "yes"
+			}
+			defaultValue := ""
+			if row.Default != "" {
+				defaultValue = "`" + row.Default + "`"
+			}
+			fmt.Fprintf(&b, "| %s | %s | %s | %s | %s | %s |\n",
+				cell(row.Column), cell(row.Type), cell(row.Key), nullable, cell(defaultValue), cell(row.Description))
+		}
+
+		for _, columns := range table.UniqueTogether {
+			fmt.Fprintf(&b, "\nUnique together: %s\n", strings.Join(columns, ", "))
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"hack/backend/dialect"
+	"hack/backend/models"
+)
+
+// sqlLiteral renders a value of field for the SQL export in dialect d.
+func sqlLiteral(d dialect.Dialect, field *models.Field, value interface{}) string {
+	// Encoded as the driver would bind it: JSON for slices and maps on
+	// MySQL and SQLite, SQLite's time format.
+	value = d.Arg(value)
+
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case bool:
+		if v {
+			return "TRUE"
+		}
+		return "FALSE"
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return fmt.Sprint(v)
+	case string:
+		return quoteLiteral(d, v)
+	case []byte:
+		if d.Name() == "postgres" {
+			return `'\x` + hex.EncodeToString(v) + "'"
+		}
+		return "X'" + hex.EncodeToString(v) + "'"
+	case time.Time:
+		if d.Name() == "mysql" {
+			return quoteLiteral(d, v.UTC().Format("2006-01-02 15:04:05.999999"))
+		}
+		return quoteLiteral(d, v.UTC().Format("2006-01-02 15:04:05.999999Z07:00"))
+	}
+
+	if field != nil && strings.HasSuffix(field.ColumnType(d), "[]") {
+		return quoteLiteral(d, postgresArray(value))
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return quoteLiteral(d, fmt.Sprint(value))
+	}
+	return quoteLiteral(d, string(encoded))
+}
+
+// quoteLiteral quotes a string, doubling quotes and, as MySQL also treats
+// backslashes as escapes, backslashes too.
+func quoteLiteral(d dialect.Dialect, value string) string {
+	if d.Name() == "mysql" {
+		value = strings.ReplaceAll(value, `\`, `\\`)
+	}
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// postgresArray writes a slice as a Postgres array literal, e.g. {"a","b"}.
+func postgresArray(value interface{}) string {
+	slice := reflect.ValueOf(value)
+	if slice.Kind() != reflect.Slice {
+		return fmt.Sprint(value)
+	}
+
+	elements := make([]string, slice.Len())
+	for i := range elements {
+		switch element := slice.Index(i).Interface().(type) {
+		case nil:
+			elements[i] = "NULL"
+		case string:
+			escaped := strings.ReplaceAll(strings.ReplaceAll(element, `\`, `\\`), `"`, `\"`)
+			elements[i] = `"` + escaped + `"`
+		default:
+			elements[i] = fmt.Sprint(element)
+		}
+	}
+	return "{" + strings.Join(elements, ",") + "}"
+}
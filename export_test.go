@@ -0,0 +1,51 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"hack/backend/dialect"
+	"hack/backend/models"
+)
+
+func TestSQLLiteral(t *testing.T) {
+	postgres, _ := dialect.Get("postgres")
+	mysql, _ := dialect.Get("mysql")
+	sqlite, _ := dialect.Get("sqlite")
+	text := &models.Field{Column: "name", Type: reflect.TypeOf("")}
+	tags := &models.Field{Column: "tags", Type: reflect.TypeOf([]string{})}
+	at := time.Date(2024, 5, 1, 12, 30, 0, 0, time.FixedZone("CEST", 2*60*60))
+
+	tests := []struct {
+		name     string
+		d        dialect.Dialect
+		field    *models.Field
+		value    interface{}
+		expected string
+	}{
+		{"null", postgres, text, nil, "NULL"},
+		{"quote", postgres, text, "O'Brien", "'O''Brien'"},
+		{"injection", postgres, text, "x'); DROP TABLE \"user\"; --", `'x''); DROP TABLE "user"; --'`},
+		{"backslash on postgres", postgres, text, `a\b`, `'a\b'`},
+		{"backslash on mysql", mysql, text, `a\'b`, `'a\\''b'`},
+		{"bool", postgres, text, true, "TRUE"},
+		{"number", sqlite, text, int64(-42), "-42"},
+		{"float", mysql, text, 1.5, "1.5"},
+		{"bytes on postgres", postgres, text, []byte{0xde, 0xad}, `'\xdead'`},
+		{"bytes on sqlite", sqlite, text, []byte{0xde, 0xad}, "X'dead'"},
+		{"time on postgres", postgres, text, at, "'2024-05-01 10:30:00Z'"},
+		{"time on mysql", mysql, text, at, "'2024-05-01 10:30:00'"},
+		{"array on postgres", postgres, tags, []string{`a"b`, "c"}, `'{"a\"b","c"}'`},
+		{"array on mysql", mysql, tags, []string{"it's"}, `'["it''s"]'`},
+		{"json", postgres, text, map[string]interface{}{"k": "v"}, `'{"k":"v"}'`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if literal := sqlLiteral(test.d, test.field, test.value); literal != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, literal)
+			}
+		})
+	}
+}
@@ -0,0 +1,14 @@
+package main
+
+import "hack/backend/models"
+
+// DefaultMaxAffectedRows caps how many rows a single /delete-many or
+// /update-many may touch, unless the model sets its own MaxAffectedRows.
+var DefaultMaxAffectedRows int64 = 1000
+
+func maxAffectedRows(model *models.Model) int64 {
+	if model.Options.MaxAffectedRows != 0 {
+		return model.Options.MaxAffectedRows
+	}
+	return DefaultMaxAffectedRows
+}
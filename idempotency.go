@@ -0,0 +1,335 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"hack/backend/models"
+	"hack/backend/storage"
+	u "hack/backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const IdempotencyHeader = "Idempotency-Key"
+
+// How long a stored response can be replayed.
+var IdempotencyTTL = 24 * time.Hour
+
+// How long a key stays claimed by a request that has not completed, so the
+// claims of a crashed server free up well before IdempotencyTTL.
+var IdempotencyLease = time.Minute
+
+var (
+	idempotencyTableMu    sync.Mutex
+	idempotencyTableReady bool
+)
+
+func ensureIdempotencyTable() error {
+	idempotencyTableMu.Lock()
+	defer idempotencyTableMu.Unlock()
+
+	if idempotencyTableReady {
+		return nil
+	}
+
+	model, _ := models.Lookup("idempotencykey")
+	statements, err := schemaStatements(model)
+	if err != nil {
+		return err
+	}
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	idempotencyTableReady = true
+	return nil
+}
+
+// idempotencyRecord is a claimed key. Status stays 0 until the request that
+// claimed it completes.
+type idempotencyRecord struct {
+	Fingerprint string
+	Status      int
+	ContentType string
+	Body        []byte
+}
+
+// idempotencyStore keeps the claimed keys and their responses.
+type idempotencyStore interface {
+	// claim takes key for fingerprint, false when a live claim exists.
+	claim(key string, fingerprint string, now time.Time) (bool, error)
+	load(key string) (idempotencyRecord, bool, error)
+	complete(key string, status int, contentType string, body []byte, now time.Time) error
+	release(key string) error
+	purge(now time.Time) error
+}
+
+// idempotencyKeys is the key store for the current storage: a table next to
+// the adapter's under SQL, memory otherwise.
+func idempotencyKeys() idempotencyStore {
+	if _, ok := store.(*storage.SQL); ok {
+		return sqlKeys{}
+	}
+	return memoryKeys
+}
+
+type sqlKeys struct{}
+
+func (sqlKeys) claim(key string, fingerprint string, now time.Time) (bool, error) {
+	db.Connect()
+	if err := ensureIdempotencyTable(); err != nil {
+		return false, err
+	}
+
+	_, err := db.Exec(`DELETE FROM "idempotencykey" WHERE "key" = ? AND "expiresAt" < ?`, key, now)
+	if err != nil {
+		return false, err
+	}
+
+	result, err := db.Exec(
+		`INSERT INTO "idempotencykey" ("key", "fingerprint", "status", "contentType", "body", "createdAt", "expiresAt") VALUES (?, ?, 0, '', ?, ?, ?) `+db.Dialect().Upsert([]string{"key"}, nil),
+		key, fingerprint, []byte{}, now, now.Add(IdempotencyLease),
+	)
+	if err != nil {
+		return false, err
+	}
+	claimed, _ := result.RowsAffected()
+	return claimed > 0, nil
+}
+
+func (sqlKeys) load(key string) (idempotencyRecord, bool, error) {
+	var record idempotencyRecord
+	err := db.QueryRow(
+		`SELECT "fingerprint", "status", "contentType", "body" FROM "idempotencykey" WHERE "key" = ?`, key,
+	).Scan(&record.Fingerprint, &record.Status, &record.ContentType, &record.Body)
+	if err == sql.ErrNoRows {
+		return record, false, nil
+	}
+	return record, err == nil, err
+}
+
+func (sqlKeys) complete(key string, status int, contentType string, body []byte, now time.Time) error {
+	_, err := db.Exec(
+		`UPDATE "idempotencykey" SET "status" = ?, "contentType" = ?, "body" = ?, "expiresAt" = ? WHERE "key" = ?`,
+		status, contentType, body, now.Add(IdempotencyTTL), key,
+	)
+	return err
+}
+
+func (sqlKeys) release(key string) error {
+	_, err := db.Exec(`DELETE FROM "idempotencykey" WHERE "key" = ?`, key)
+	return err
+}
+
+func (sqlKeys) purge(now time.Time) error {
+	if !db.IsConnected() {
+		return nil
+	}
+	if err := ensureIdempotencyTable(); err != nil {
+		return err
+	}
+	_, err := db.Exec(`DELETE FROM "idempotencykey" WHERE "expiresAt" < ?`, now)
+	return err
+}
+
+// memoryIdempotency is the key store used with the in-memory storage, which
+// loses its keys on restart just like its rows.
+type memoryIdempotency struct {
+	mu      sync.Mutex
+	records map[string]memoryKey
+}
+
+type memoryKey struct {
+	idempotencyRecord
+	expiresAt time.Time
+}
+
+var memoryKeys = &memoryIdempotency{records: map[string]memoryKey{}}
+
+func (this *memoryIdempotency) claim(key string, fingerprint string, now time.Time) (bool, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if existing, ok := this.records[key]; ok && !existing.expiresAt.Before(now) {
+		return false, nil
+	}
+	this.records[key] = memoryKey{idempotencyRecord{Fingerprint: fingerprint}, now.Add(IdempotencyLease)}
+	return true, nil
+}
+
+func (this *memoryIdempotency) load(key string) (idempotencyRecord, bool, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	record, ok := this.records[key]
+	return record.idempotencyRecord, ok, nil
+}
+
+func (this *memoryIdempotency) complete(key string, status int, contentType string, body []byte, now time.Time) error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if record, ok := this.records[key]; ok {
+		record.Status, record.ContentType, record.Body = status, contentType, bytes.Clone(body)
+		record.expiresAt = now.Add(IdempotencyTTL)
+		this.records[key] = record
+	}
+	return nil
+}
+
+func (this *memoryIdempotency) release(key string) error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	delete(this.records, key)
+	return nil
+}
+
+func (this *memoryIdempotency) purge(now time.Time) error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	for key, record := range this.records {
+		if record.expiresAt.Before(now) {
+			delete(this.records, key)
+		}
+	}
+	return nil
+}
+
+// requestFingerprint hashes everything that makes two requests "the same".
+func requestFingerprint(method string, path string, contentType string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method))
+	hash.Write([]byte{0})
+	hash.Write([]byte(path))
+	hash.Write([]byte{0})
+	hash.Write([]byte(contentType))
+	hash.Write([]byte{0})
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// recordingWriter keeps a copy of the response body for later replay.
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (this *recordingWriter) Write(data []byte) (int, error) {
+	this.body.Write(data)
+	return this.ResponseWriter.Write(data)
+}
+
+func (this *recordingWriter) WriteString(data string) (int, error) {
+	this.body.WriteString(data)
+	return this.ResponseWriter.WriteString(data)
+}
+
+// Idempotent makes a mutating endpoint honor the Idempotency-Key header.
+// The first request with a key is executed and its response stored; retries
+// with the same payload get that response back, and reusing the key for a
+// different payload is rejected.
+func Idempotent() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			AbortRespond(c, http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		fingerprint := requestFingerprint(c.Request.Method, c.FullPath(), c.GetHeader("Content-Type"), body)
+
+		keys := idempotencyKeys()
+		claimed, err := keys.claim(key, fingerprint, time.Now())
+		if err != nil {
+			u.ErrorF("Failed to claim idempotency key:\t%s\n", err.Error())
+			AbortRespond(c, http.StatusInternalServerError, gin.H{"error": "Idempotency store unavailable"})
+			return
+		}
+		if !claimed {
+			replayIdempotent(c, keys, key, fingerprint)
+			return
+		}
+
+		writer := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		// A handler that panics must not hold the key until its lease runs out.
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				if err := keys.release(key); err != nil {
+					u.ErrorF("Failed to release idempotency key:\t%s\n", err.Error())
+				}
+				panic(recovered)
+			}
+		}()
+		c.Next()
+
+		// Server errors are not final, let the client retry with the same key.
+		if writer.Status() >= http.StatusInternalServerError {
+			if err := keys.release(key); err != nil {
+				u.ErrorF("Failed to release idempotency key:\t%s\n", err.Error())
+			}
+			return
+		}
+
+		if err := keys.complete(key, writer.Status(), writer.Header().Get("Content-Type"), writer.body.Bytes(), time.Now()); err != nil {
+			u.ErrorF("Failed to store idempotent response:\t%s\n", err.Error())
+		}
+	}
+}
+
+func replayIdempotent(c *gin.Context, keys idempotencyStore, key string, fingerprint string) {
+	record, found, err := keys.load(key)
+	if err != nil {
+		u.ErrorF("Failed to load idempotency key:\t%s\n", err.Error())
+		AbortRespond(c, http.StatusInternalServerError, gin.H{"error": "Idempotency store unavailable"})
+		return
+	}
+	if !found {
+		// Released by a failed request between our claim and lookup.
+		AbortRespond(c, http.StatusConflict, gin.H{"error": "Idempotency-Key is being processed, retry later"})
+		return
+	}
+
+	if record.Fingerprint != fingerprint {
+		AbortRespond(c, http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used for a different request"})
+		return
+	}
+
+	if record.Status == 0 {
+		AbortRespond(c, http.StatusConflict, gin.H{"error": "Idempotency-Key is being processed, retry later"})
+		return
+	}
+
+	c.Header("Idempotent-Replayed", "true")
+	c.Data(record.Status, record.ContentType, record.Body)
+	c.Abort()
+}
+
+// PurgeIdempotencyKeys removes expired keys every interval until stop is closed.
+func PurgeIdempotencyKeys(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := idempotencyKeys().purge(time.Now()); err != nil {
+				u.ErrorF("Failed to purge idempotency keys:\t%s\n", err.Error())
+			}
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"hack/backend/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// A handler that panics releases its key, so the retry runs instead of
+// getting 409 until the claim expires.
+func TestIdempotentReleasesOnPanic(t *testing.T) {
+	previousStore, previousKeys := store, memoryKeys
+	t.Cleanup(func() { store, memoryKeys = previousStore, previousKeys })
+	store = storage.NewMemory()
+	memoryKeys = &memoryIdempotency{records: map[string]memoryKey{}}
+
+	calls := 0
+	router := gin.New()
+	router.Use(gin.CustomRecovery(func(c *gin.Context, _ any) {
+		c.AbortWithStatus(http.StatusInternalServerError)
+	}))
+	router.POST("/create", Idempotent(), func(c *gin.Context) {
+		calls++
+		if calls == 1 {
+			panic("boom")
+		}
+		c.JSON(http.StatusOK, gin.H{"calls": calls})
+	})
+
+	call := func() int {
+		request := httptest.NewRequest(http.MethodPost, "/create", strings.NewReader(`{}`))
+		request.Header.Set(IdempotencyHeader, "panics")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		return recorder.Code
+	}
+
+	if code := call(); code != http.StatusInternalServerError {
+		t.Fatalf("expected status 500 from the panic, got %d", code)
+	}
+	if code := call(); code != http.StatusOK || calls != 2 {
+		t.Errorf("expected the retry to run, got %d after %d calls", code, calls)
+	}
+}
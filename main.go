@@ -2,14 +2,20 @@ package main
 
 import (
 	"bytes"
-	_ "database/sql"
 	"encoding/csv"
+	"errors"
+	"hack/backend/config"
+	"hack/backend/dialect"
+	"hack/backend/docs"
+	"hack/backend/models"
+	"hack/backend/schema"
 	s "hack/backend/server"
+	"hack/backend/storage"
 	u "hack/backend/utils"
 
 	"fmt"
 	"net/http"
-	"reflect" // Added reflect
+	"os"
 	"strconv"
 	"strings"
 	"time"
@@ -20,81 +26,40 @@ import (
 
 var db s.Database
 
-// goTypeToSQL maps Go types to their corresponding SQL types.
-func goTypeToSQL(t reflect.Type) string {
-	switch t.Kind() {
-	case reflect.String:
-		return "TEXT"
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		return "INTEGER"
-	case reflect.Bool:
-		return "BOOLEAN"
-	case reflect.Float32, reflect.Float64:
-		return "REAL"
-	}
-	// Special case for time.Time
-	if t.String() == "time.Time" {
-		return "TIMESTAMP"
-	}
-	return "TEXT" // Default to TEXT for other types
-}
-
-// GenerateCreateTableSQL generates a CREATE TABLE SQL statement from a Go struct.
-func GenerateCreateTableSQL(s interface{}) (string, error) {
-	t := reflect.TypeOf(s)
-	if t.Kind() != reflect.Struct {
-		return "", fmt.Errorf("expected a struct")
-	}
-
-	tableName := strings.ToLower(t.Name())
-	var columns []string
-	var primaryKeys []string
-
-	for i := 0; i < t.NumField(); i++ {
-		field := t.Field(i)
-		dbTag := field.Tag.Get("db")
-		if dbTag == "" {
-			continue // Skip fields without a db tag
-		}
+// settings is the loaded configuration; Router() uses the defaults until
+// main() loads it.
+var settings = config.Default()
 
-		columnName := dbTag
-		sqlType := goTypeToSQL(field.Type)
-		columns = append(columns, fmt.Sprintf(`"%s" %s`, columnName, sqlType))
-
-		if pkTag := field.Tag.Get("pk"); pkTag == "true" {
-			primaryKeys = append(primaryKeys, fmt.Sprintf(`"%s"`, columnName))
-		}
-	}
-
-	if len(columns) == 0 {
-		return "", fmt.Errorf("no fields with db tags found in struct")
+// schemaStatements returns the DDL that creates a model's table and indexes.
+func schemaStatements(model *models.Model) ([]string, error) {
+	query, err := models.GenerateCreateTableSQL(db.Dialect(), model)
+	if err != nil {
+		return nil, err
 	}
+	return append([]string{query}, models.GenerateIndexSQL(db.Dialect(), model)...), nil
+}
 
-	query := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS "%s" (%s`, tableName, strings.Join(columns, ", "))
-	if len(primaryKeys) > 0 {
-		query += fmt.Sprintf(", PRIMARY KEY (%s)", strings.Join(primaryKeys, ", "))
+// rebound rewrites canonical statements for the configured dialect, for DDL
+// handed out rather than executed.
+func rebound(statements []string) []string {
+	result := make([]string, len(statements))
+	for i, statement := range statements {
+		result[i] = dialect.Rebind(db.Dialect(), statement)
 	}
-	query += ");"
-
-	return query, nil
+	return result
 }
 
 func createSchema(db *s.Database) error {
-	schemas := []interface{}{
-		User{},
-		Session{},
-		Account{},
-		Verification{},
-	}
-
-	for _, schema := range schemas {
-		query, err := GenerateCreateTableSQL(schema) // Use the local function
+	for _, model := range models.All() {
+		statements, err := schemaStatements(model)
 		if err != nil {
 			return err
 		}
-		_, err = db.Exec(query)
-		if err != nil {
-			return err
+		for _, statement := range statements {
+			_, err = db.Exec(statement)
+			if err != nil {
+				return err
+			}
 		}
 	}
 	return nil
@@ -114,242 +79,135 @@ func Router() *gin.Engine {
 		c.Header("Referrer-Policy", "strict-origin")
 		c.Header("X-Content-Type-Options", "nosniff")
 		c.Header("Permissions-Policy", "geolocation=(),midi=(),sync-xhr=(),microphone=(),camera=(),magnetometer=(),gyroscope=(),fullscreen=(self),payment=()")
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, Content-Length")
+		if origin := allowedOrigin(c.GetHeader("Origin")); origin != "" {
+			c.Header("Access-Control-Allow-Origin", origin)
+			if origin != "*" {
+				c.Header("Vary", "Origin")
+			}
+		}
+		c.Header("Access-Control-Allow-Methods", strings.Join(settings.CORS.AllowMethods, ", "))
+		c.Header("Access-Control-Allow-Headers", strings.Join(settings.CORS.AllowHeaders, ", "))
 		c.Next()
 	})
 
-	api := router.Group("/")
+	api := router.Group("/", Negotiate(), RequireAPIKey(settings.Auth.APIKey), RequireDatabase(), ReadYourWrites(time.Duration(settings.Features.ReadAfterWriteWindow)))
 
 	router.Use(static.Serve("/assets", static.LocalFile("./public/", true)))
 
 	{
 		api.GET("/ping", func(c *gin.Context) {
-			c.JSON(http.StatusOK, gin.H{"message": "pong"})
+			Respond(c, http.StatusOK, gin.H{"message": "pong"})
 		})
 
 		api.GET("/", func(c *gin.Context) {
-			c.JSON(http.StatusOK, gin.H{"message": "ok"})
+			Respond(c, http.StatusOK, gin.H{"message": "ok"})
 		})
 
 		api.POST("/count", func(c *gin.Context) {
 			var requestBody CountRequestBody
-			if err := c.BindJSON(&requestBody); err != nil {
-				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Wrong call to API"})
+			if err := BindBody(c, &requestBody); err != nil {
+				AbortRespond(c, http.StatusBadRequest, gin.H{"error": "Wrong call to API"})
 				return
 			}
 
-			db.Connect()
-
-			var whereParts []string
-			var args []interface{}
-			for i, clause := range requestBody.Where {
-				if i > 0 {
-					whereParts = append(whereParts, clause.Connector)
-				}
-				sqlOperator := "="
-				if clause.Operator != "eq" {
-				}
-				whereParts = append(whereParts, fmt.Sprintf("\"%s\" %s $%d", clause.Field, sqlOperator, i+1))
-				args = append(args, clause.Value)
+			model, ok := resolveModel(c, requestBody.Model)
+			if !ok {
+				return
 			}
 
-			whereClause := strings.Join(whereParts, " ")
-			sqlQuery := fmt.Sprintf("SELECT COUNT(*) FROM \"%s\" WHERE %s", requestBody.Model, whereClause)
-
-			rows, err := db.Query(sqlQuery, args...)
+			count, err := store.Count(c.Request.Context(), model, storage.Query{Where: requestBody.Where, IncludeDeleted: requestBody.IncludeDeleted})
 			if err != nil {
-				u.ErrorF("Query Execution Failed:\t%s\n", err.Error())
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Query Execution Failed"})
+				status, payload := storageFailure(err, "Query Execution Failed")
+				Respond(c, status, payload)
 				return
 			}
-			defer rows.Close()
-
-			var count int
-			if rows.Next() {
-				if err := rows.Scan(&count); err != nil {
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan row"})
-					return
-				}
-			}
 
-			c.JSON(http.StatusOK, gin.H{"count": count})
+			Respond(c, http.StatusOK, gin.H{"count": count})
 		})
-		api.POST("/create", func(c *gin.Context) {
+		api.POST("/create", Idempotent(), func(c *gin.Context) {
 			var requestBody CreateRequestBody
-			if err := c.BindJSON(&requestBody); err != nil {
-				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Wrong call to API"})
+			if err := BindBody(c, &requestBody); err != nil {
+				AbortRespond(c, http.StatusBadRequest, gin.H{"error": "Wrong call to API"})
 				return
 			}
 
-			db.Connect()
-
-			data := requestBody.Data.(map[string]interface{})
-			var columns []string
-			var values []interface{}
-			var valuePlaceholders []string
-			i := 1
-			for col, val := range data {
-				columns = append(columns, col)
-				values = append(values, val)
-				valuePlaceholders = append(valuePlaceholders, fmt.Sprintf("$%d", i))
-				i++
-			}
-
-			quotedColumns := make([]string, len(columns))
-			for i, col := range columns {
-				quotedColumns[i] = fmt.Sprintf("\"%s\"", col)
-			}
-			columnNames := strings.Join(quotedColumns, ", ")
-			sqlQuery := fmt.Sprintf("INSERT INTO \"%s\" (%s) VALUES (%s)", requestBody.Model, columnNames, strings.Join(valuePlaceholders, ", "))
-
-			rows, err := db.Query(sqlQuery, values...)
-			if err != nil {
-				u.ErrorF("Query Execution Failed:\t%s\n", err.Error())
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Query Execution Failed"})
+			model, ok := resolveModel(c, requestBody.Model)
+			if !ok {
 				return
 			}
-			defer rows.Close()
 
-			c.JSON(http.StatusOK, data)
+			status, payload := runCreate(c.Request.Context(), store, model, requestBody.Data, requestBody.Select)
+			Respond(c, status, payload)
 		})
-		api.POST("/delete", func(c *gin.Context) {
+		api.POST("/delete", Idempotent(), func(c *gin.Context) {
 			var requestBody DeleteRequestBody
-			if err := c.BindJSON(&requestBody); err != nil {
-				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Wrong call to API"})
+			if err := BindBody(c, &requestBody); err != nil {
+				AbortRespond(c, http.StatusBadRequest, gin.H{"error": "Wrong call to API"})
 				return
 			}
 
-			db.Connect()
-
-			var whereParts []string
-			var args []interface{}
-			for i, clause := range requestBody.Where {
-				if i > 0 {
-					whereParts = append(whereParts, clause.Connector)
-				}
-				sqlOperator := "="
-				if clause.Operator != "eq" {
-				}
-				whereParts = append(whereParts, fmt.Sprintf("\"%s\" %s $%d", clause.Field, sqlOperator, i+1))
-				args = append(args, clause.Value)
-			}
-
-			whereClause := strings.Join(whereParts, " ")
-			sqlQuery := fmt.Sprintf("DELETE FROM \"%s\" WHERE %s", requestBody.Model, whereClause)
-
-			rows, err := db.Query(sqlQuery, args...)
-			if err != nil {
-				u.ErrorF("Query Execution Failed:\t%s\n", err.Error())
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Query Execution Failed"})
+			model, ok := resolveModel(c, requestBody.Model)
+			if !ok {
 				return
 			}
-			defer rows.Close()
 
-			c.JSON(http.StatusOK, gin.H{"message": "success"})
+			status, payload := runDelete(c.Request.Context(), store, model, requestBody.Where)
+			Respond(c, status, payload)
 		})
-		api.POST("/delete-many", func(c *gin.Context) {
+		api.POST("/delete-many", Idempotent(), func(c *gin.Context) {
 			var requestBody DeleteManyRequestBody
-			if err := c.BindJSON(&requestBody); err != nil {
-				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Wrong call to API"})
+			if err := BindBody(c, &requestBody); err != nil {
+				AbortRespond(c, http.StatusBadRequest, gin.H{"error": "Wrong call to API"})
 				return
 			}
 
-			db.Connect()
-
-			var whereParts []string
-			var args []interface{}
-			for i, clause := range requestBody.Where {
-				if i > 0 {
-					whereParts = append(whereParts, clause.Connector)
-				}
-				sqlOperator := "="
-				if clause.Operator != "eq" {
-				}
-				whereParts = append(whereParts, fmt.Sprintf("\"%s\" %s $%d", clause.Field, sqlOperator, i+1))
-				args = append(args, clause.Value)
-			}
-
-			whereClause := strings.Join(whereParts, " ")
-			sqlQuery := fmt.Sprintf("DELETE FROM \"%s\" WHERE %s", requestBody.Model, whereClause)
-
-			rows, err := db.Query(sqlQuery, args...)
-			if err != nil {
-				u.ErrorF("Query Execution Failed:\t%s\n", err.Error())
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Query Execution Failed"})
+			model, ok := resolveModel(c, requestBody.Model)
+			if !ok {
 				return
 			}
-			defer rows.Close()
 
-			c.JSON(http.StatusOK, gin.H{"message": "success"})
+			status, payload := runDeleteMany(c.Request.Context(), store, model, requestBody.Where, requestBody.AllowAll, requestBody.DryRun)
+			Respond(c, status, payload)
 		})
 		api.POST("/find-many", func(c *gin.Context) {
 			var requestBody FindManyRequestBody
-			if err := c.BindJSON(&requestBody); err != nil {
-				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Wrong call to API"})
+			if err := BindBody(c, &requestBody); err != nil {
+				AbortRespond(c, http.StatusBadRequest, gin.H{"error": "Wrong call to API"})
 				return
 			}
 
-			db.Connect()
-
-			var whereParts []string
-			var args []interface{}
-			for i, clause := range requestBody.Where {
-				if i > 0 {
-					whereParts = append(whereParts, clause.Connector)
-				}
-				sqlOperator := "="
-				if clause.Operator != "eq" {
-				}
-				whereParts = append(whereParts, fmt.Sprintf("\"%s\" %s $%d", clause.Field, sqlOperator, i+1))
-				args = append(args, clause.Value)
+			model, ok := resolveModel(c, requestBody.Model)
+			if !ok {
+				return
 			}
 
-			whereClause := strings.Join(whereParts, " ")
-			sqlQuery := fmt.Sprintf("SELECT * FROM \"%s\" WHERE %s", requestBody.Model, whereClause)
-
-			rows, err := db.Query(sqlQuery, args...)
+			sortBy, err := parseSortBy(requestBody.SortBy)
 			if err != nil {
-				u.ErrorF("Query Execution Failed:\t%s\n", err.Error())
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Query Execution Failed"})
+				AbortRespond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if err := checkSelect(model, requestBody.Select); err != nil {
+				AbortRespond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
 				return
 			}
-			defer rows.Close()
 
-			columns, err := rows.Columns()
+			results, err := store.FindMany(c.Request.Context(), model, storage.Query{
+				Where:          requestBody.Where,
+				SortBy:         sortBy,
+				Limit:          requestBody.Limit,
+				Offset:         requestBody.Offset,
+				IncludeDeleted: requestBody.IncludeDeleted,
+			})
 			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get columns"})
+				status, payload := storageFailure(err, "Query Execution Failed")
+				Respond(c, status, payload)
 				return
 			}
-
-			var results []map[string]interface{}
-			for rows.Next() {
-				values := make([]interface{}, len(columns))
-				valuePtrs := make([]interface{}, len(columns))
-				for i := range columns {
-					valuePtrs[i] = &values[i]
-				}
-
-				if err := rows.Scan(valuePtrs...); err != nil {
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan row"})
-					return
-				}
-
-				result := make(map[string]interface{})
-				for i, col := range columns {
-					val := values[i]
-					b, ok := val.([]byte)
-					if ok {
-						result[col] = string(b)
-					} else {
-						result[col] = val
-					}
-				}
-				results = append(results, result)
+			for i, result := range results {
+				results[i] = selectFields(requestBody.Select, result)
 			}
 
-			c.JSON(http.StatusOK, results)
+			Respond(c, http.StatusOK, results)
 		})
 		api.POST("/find-one", func(c *gin.Context) {
 			defer func() {
@@ -359,201 +217,202 @@ func Router() *gin.Engine {
 			}()
 
 			var requestBody FindOneRequestBody
-			if err := c.BindJSON(&requestBody); err != nil {
-				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Wrong call to API"})
+			if err := BindBody(c, &requestBody); err != nil {
+				AbortRespond(c, http.StatusBadRequest, gin.H{"error": "Wrong call to API"})
 				return
 			}
 
-			if len(requestBody.Where) == 0 {
-				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "A 'where' clause is required for find-one"})
+			model, ok := resolveModel(c, requestBody.Model)
+			if !ok {
 				return
 			}
 
-			db.Connect()
-
-			var whereParts []string
-			var args []interface{}
-			for i, clause := range requestBody.Where {
-				if i > 0 {
-					whereParts = append(whereParts, clause.Connector)
-				}
-				sqlOperator := "="
-				if clause.Operator != "eq" {
-				}
-				whereParts = append(whereParts, fmt.Sprintf("\"%s\" %s $%d", clause.Field, sqlOperator, i+1))
-				args = append(args, clause.Value)
+			if len(requestBody.Where) == 0 {
+				AbortRespond(c, http.StatusBadRequest, gin.H{"error": "A 'where' clause is required for find-one"})
+				return
+			}
+			if err := checkSelect(model, requestBody.Select); err != nil {
+				AbortRespond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
 			}
 
-			whereClause := strings.Join(whereParts, " ")
-			sqlQuery := fmt.Sprintf("SELECT * FROM \"%s\" WHERE %s", requestBody.Model, whereClause)
-
-			rows, err := db.Query(sqlQuery, args...)
+			result, err := store.FindOne(c.Request.Context(), model, storage.Query{Where: requestBody.Where, IncludeDeleted: requestBody.IncludeDeleted})
+			if errors.Is(err, storage.ErrNotFound) {
+				Respond(c, http.StatusOK, gin.H{"error": "empty"})
+				return
+			}
 			if err != nil {
-				u.ErrorF("Query: %s, Args: %v", sqlQuery, args)
-				u.ErrorF("Query Execution Failed:\t%s\n", err.Error())
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Query Execution Failed from find-one handler"})
+				status, payload := storageFailure(err, "Query Execution Failed from find-one handler")
+				Respond(c, status, payload)
 				return
 			}
-			defer rows.Close()
 
-			columns, err := rows.Columns()
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get columns"})
+			Respond(c, http.StatusOK, selectFields(requestBody.Select, result))
+		})
+		api.POST("/update", Idempotent(), func(c *gin.Context) {
+			var requestBody UpdateRequestBody
+			if err := BindBody(c, &requestBody); err != nil {
+				AbortRespond(c, http.StatusBadRequest, gin.H{"error": "Wrong call to API"})
 				return
 			}
 
-			values := make([]interface{}, len(columns))
-			valuePtrs := make([]interface{}, len(columns))
-			for i := range columns {
-				valuePtrs[i] = &values[i]
+			model, ok := resolveModel(c, requestBody.Model)
+			if !ok {
+				return
 			}
 
-			if rows.Next() {
-				if err := rows.Scan(valuePtrs...); err != nil {
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan row"})
-					return
-				}
-			} else {
-				c.JSON(http.StatusOK, gin.H{"error": "empty"})
+			status, payload := runUpdate(c.Request.Context(), store, model, requestBody.Where, requestBody.Update, requestBody.IfMatch)
+			Respond(c, status, payload)
+		})
+		api.POST("/update-many", Idempotent(), func(c *gin.Context) {
+			var requestBody UpdateManyRequestBody
+			if err := BindBody(c, &requestBody); err != nil {
+				AbortRespond(c, http.StatusBadRequest, gin.H{"error": "Wrong call to API"})
 				return
 			}
 
-			result := make(map[string]interface{})
-			for i, col := range columns {
-				val := values[i]
-				b, ok := val.([]byte)
-				if ok {
-					result[col] = string(b)
-				} else {
-					result[col] = val
-				}
+			model, ok := resolveModel(c, requestBody.Model)
+			if !ok {
+				return
 			}
 
-			c.JSON(http.StatusOK, result)
+			status, payload := runUpdateMany(c.Request.Context(), store, model, requestBody.Where, requestBody.Update, requestBody.AllowAll, requestBody.DryRun)
+			Respond(c, status, payload)
 		})
-		api.POST("/update", func(c *gin.Context) {
-			var requestBody UpdateRequestBody
-			if err := c.BindJSON(&requestBody); err != nil {
-				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Wrong call to API"})
+		api.POST("/transaction", Idempotent(), func(c *gin.Context) {
+			var requestBody TransactionRequestBody
+			if err := BindBody(c, &requestBody); err != nil {
+				AbortRespond(c, http.StatusBadRequest, gin.H{"error": "Wrong call to API"})
+				return
+			}
+			if len(requestBody.Operations) == 0 {
+				AbortRespond(c, http.StatusBadRequest, gin.H{"error": "No operations given"})
 				return
 			}
 
-			db.Connect()
-
-			updateData := requestBody.Update.(map[string]interface{})
-			var setParts []string
-			var args []interface{}
-			i := 1
-			for col, val := range updateData {
-				setParts = append(setParts, fmt.Sprintf("%s = $%d", col, i))
-				args = append(args, val)
-				i++
+			status, payload := runTransaction(c.Request.Context(), requestBody.Operations)
+			Respond(c, status, payload)
+		})
+		api.GET("/schema", func(c *gin.Context) {
+			connected, reports, err := schemaReport(c.Request.Context())
+			if err != nil {
+				u.ErrorF("Failed to inspect schema:\t%s\n", err.Error())
+				Respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to inspect schema"})
+				return
 			}
 
-			var whereParts []string
-			for _, clause := range requestBody.Where {
-				if len(whereParts) > 0 {
-					whereParts = append(whereParts, clause.Connector)
-				}
-				sqlOperator := "="
-				if clause.Operator != "eq" {
+			public := make([]schema.ModelReport, 0, len(reports))
+			for _, report := range reports {
+				if !report.Internal {
+					public = append(public, report)
 				}
-				whereParts = append(whereParts, fmt.Sprintf("\"%s\" %s $%d", clause.Field, sqlOperator, i))
-				args = append(args, clause.Value)
-				i++
 			}
+			Respond(c, http.StatusOK, gin.H{"connected": connected, "models": public})
+		})
 
-			setClause := strings.Join(setParts, ", ")
-			whereClause := strings.Join(whereParts, " ")
-			sqlQuery := fmt.Sprintf("UPDATE %s SET %s WHERE %s", requestBody.Model, setClause, whereClause)
-
-			rows, err := db.Query(sqlQuery, args...)
-			if err != nil {
-				u.ErrorF("Query Execution Failed:\t%s\n", err.Error())
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Query Execution Failed"})
+		api.POST("/create-schema", func(c *gin.Context) {
+			var requestBody CreateSchemaRequestBody
+			if err := BindBody(c, &requestBody); err != nil {
+				AbortRespond(c, http.StatusBadRequest, gin.H{"error": "Wrong call to API"})
 				return
 			}
-			defer rows.Close()
-
-			c.JSON(http.StatusOK, gin.H{"message": "success"})
-		})
-		api.POST("/update-many", func(c *gin.Context) {
-			var requestBody UpdateManyRequestBody
-			if err := c.BindJSON(&requestBody); err != nil {
-				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Wrong call to API"})
+			if len(requestBody.Tables) == 0 {
+				Respond(c, http.StatusBadRequest, gin.H{"error": "No tables given"})
 				return
 			}
 
-			db.Connect()
-
-			updateData := requestBody.Update.(map[string]interface{})
-			var setParts []string
-			var args []interface{}
-			i := 1
-			for col, val := range updateData {
-				setParts = append(setParts, fmt.Sprintf("%s = $%d", col, i))
-				args = append(args, val)
-				i++
+			plan, err := planSchema(requestBody.Tables)
+			if err != nil {
+				Respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
 			}
 
-			var whereParts []string
-			for _, clause := range requestBody.Where {
-				if len(whereParts) > 0 {
-					whereParts = append(whereParts, clause.Connector)
-				}
-				sqlOperator := "="
-				if clause.Operator != "eq" {
-				}
-				whereParts = append(whereParts, fmt.Sprintf("\"%s\" %s $%d", clause.Field, sqlOperator, i))
-				args = append(args, clause.Value)
-				i++
+			path := requestBody.File
+			if path == "" {
+				path = fmt.Sprintf("better-auth_migrations/%s.sql", time.Now().Format("2006-01-02T15-04-05"))
 			}
 
-			setClause := strings.Join(setParts, ", ")
-			whereClause := strings.Join(whereParts, " ")
-			sqlQuery := fmt.Sprintf("UPDATE %s SET %s WHERE %s", requestBody.Model, setClause, whereClause)
+			// The memory storage needs no tables, its models are enough.
+			_, sqlStore := store.(*storage.SQL)
+			if sqlStore && requestBody.Apply {
+				db.Connect()
 
-			rows, err := db.Query(sqlQuery, args...)
-			if err != nil {
-				u.ErrorF("Query Execution Failed:\t%s\n", err.Error())
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Query Execution Failed"})
-				return
+				if err := applySchema(requestBody.Tables, plan); err != nil {
+					u.ErrorF("Failed to apply schema:\t%s\n", err.Error())
+					Respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to apply schema"})
+					return
+				}
+			}
+			// Tables are only served once they exist. Without apply the
+			// client runs the code itself and calls again with apply.
+			if !sqlStore || requestBody.Apply {
+				if err := registerSchema(plan); err != nil {
+					u.ErrorF("Failed to register schema:\t%s\n", err.Error())
+					Respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to register schema"})
+					return
+				}
 			}
-			defer rows.Close()
 
-			c.JSON(http.StatusOK, gin.H{"message": "success"})
-		})
-		api.POST("/create-schema", func(c *gin.Context) {
-			c.JSON(http.StatusNotImplemented, gin.H{"error": "Not Implemented"})
+			Respond(c, http.StatusOK, gin.H{
+				"code":    strings.Join(rebound(plan.Statements), "\n"),
+				"path":    path,
+				"applied": requestBody.Apply,
+			})
 		})
 	}
 
+	if !settings.Features.Admin {
+		return router
+	}
+
 	admin := router.Group("/admin")
+	if settings.Auth.AdminPassword != "" {
+		admin.Use(gin.BasicAuth(gin.Accounts{settings.Auth.AdminUser: settings.Auth.AdminPassword}))
+	}
 	{
 		admin.GET("/dashboard", func(c *gin.Context) {
+			config := db.Config()
 			c.HTML(http.StatusOK, "dashboard.html", gin.H{
 				"Title":       "Admin Dashboard",
-				"Host":        db.Config.Host,
-				"Port":        db.Config.Port,
-				"User":        db.Config.User,
-				"Password":    db.Config.Password,
-				"Database":    db.Config.Database,
-				"SSL":         db.Config.SSL,
-				"IsConnected": db.IsConnected,
+				"Host":        config.Host,
+				"Port":        config.Port,
+				"User":        config.User,
+				"Password":    config.Password,
+				"Database":    config.Database,
+				"SSL":         config.SSL,
+				"Driver":      config.Driver,
+				"Drivers":     []string{s.Driver(s.POSTGRESQL), s.Driver(s.MYSQL), s.Driver(s.SQLITE)},
+				"IsConnected": db.IsConnected(),
+				"Breaker":     db.Breaker(),
+				"Models":      models.All(),
+				"Plugins":     EnabledPlugins,
+				"Retention":   int(SoftDeleteRetention.Hours() / 24),
 			})
 		})
 
 		admin.POST("/dashboard/save", func(c *gin.Context) {
-			db.Config.Host = c.PostForm("host")
+			config := db.Config()
+			config.Host = c.PostForm("host")
 			port, _ := strconv.ParseUint(c.PostForm("port"), 10, 16)
-			db.Config.Port = uint16(port)
-			db.Config.User = c.PostForm("user")
-			db.Config.Password = c.PostForm("password")
-			db.Config.Database = c.PostForm("database")
-			db.Config.SSL = c.PostForm("ssl")
-
-			// Invalidate current connection
-			db.Close()
+			config.Port = uint16(port)
+			config.User = c.PostForm("user")
+			config.Password = c.PostForm("password")
+			config.Database = c.PostForm("database")
+			config.SSL = c.PostForm("ssl")
+			if driver := c.PostForm("driver"); driver != "" {
+				config.Driver = driver
+			}
+
+			// Requests in progress finish on the old connection
+			err := db.Reconfigure(c.Request.Context(), config)
+			idempotencyTableMu.Lock()
+			idempotencyTableReady = false
+			idempotencyTableMu.Unlock()
+			if err != nil {
+				u.ErrorF("Failed to connect with the new database settings:\t%s\n", err.Error())
+				c.String(http.StatusInternalServerError, "Failed to connect with the new settings, the current connection is kept: %s", err.Error())
+				return
+			}
 
 			// Redirect back to dashboard
 			c.Redirect(http.StatusFound, "/admin/dashboard")
@@ -569,56 +428,25 @@ func Router() *gin.Engine {
 			separator := c.PostForm("separator")
 
 			if format == "csv" {
-				db.Connect()
-				tablesQuery, err := db.Query("SELECT table_name FROM information_schema.tables WHERE table_schema = 'public'")
-				if err != nil {
-					c.String(http.StatusInternalServerError, "Failed to get tables: %s", err.Error())
-					return
-				}
-				defer tablesQuery.Close()
-
 				var buffer bytes.Buffer
 				csvWriter := csv.NewWriter(&buffer)
 				csvWriter.Comma = []rune(separator)[0]
 
-				for tablesQuery.Next() {
-					var tableName string
-					if err := tablesQuery.Scan(&tableName); err != nil {
-						c.String(http.StatusInternalServerError, "Failed to scan table name: %s", err.Error())
-						return
-					}
-
-					rows, err := db.Query(fmt.Sprintf("SELECT * FROM \"%s\"", tableName))
-					if err != nil {
-						c.String(http.StatusInternalServerError, "Failed to get data from table %s: %s", tableName, err.Error())
-						return
-					}
-					defer rows.Close()
-
-					columns, err := rows.Columns()
+				for _, model := range models.All() {
+					rows, err := store.FindMany(c.Request.Context(), model, storage.Query{IncludeDeleted: true})
 					if err != nil {
-						c.String(http.StatusInternalServerError, "Failed to get columns from table %s: %s", tableName, err.Error())
+						c.String(http.StatusInternalServerError, "Failed to get data from table %s: %s", model.Table, err.Error())
 						return
 					}
 
+					columns := model.Columns()
 					csvWriter.Write(columns)
 
-					for rows.Next() {
-						values := make([]interface{}, len(columns))
-						valuePtrs := make([]interface{}, len(columns))
-						for i := range columns {
-							valuePtrs[i] = &values[i]
-						}
-
-						if err := rows.Scan(valuePtrs...); err != nil {
-							c.String(http.StatusInternalServerError, "Failed to scan row: %s", err.Error())
-							return
-						}
-
+					for _, row := range rows {
 						var record []string
-						for _, val := range values {
-							if b, ok := val.([]byte); ok {
-								record = append(record, string(b))
+						for _, column := range columns {
+							if val := row[column]; val == nil {
+								record = append(record, "")
 							} else {
 								record = append(record, fmt.Sprintf("%v", val))
 							}
@@ -634,57 +462,29 @@ func Router() *gin.Engine {
 				c.Data(http.StatusOK, "text/csv", buffer.Bytes())
 
 			} else if format == "sql" {
-				db.Connect()
-				tablesQuery, err := db.Query("SELECT table_name FROM information_schema.tables WHERE table_schema = 'public'")
-				if err != nil {
-					c.String(http.StatusInternalServerError, "Failed to get tables: %s", err.Error())
-					return
-				}
-				defer tablesQuery.Close()
-
 				var buffer bytes.Buffer
+				d := db.Dialect()
 
-				for tablesQuery.Next() {
-					var tableName string
-					if err := tablesQuery.Scan(&tableName); err != nil {
-						c.String(http.StatusInternalServerError, "Failed to scan table name: %s", err.Error())
-						return
-					}
-
-					rows, err := db.Query(fmt.Sprintf("SELECT * FROM \"%s\"", tableName))
+				for _, model := range models.All() {
+					rows, err := store.FindMany(c.Request.Context(), model, storage.Query{IncludeDeleted: true})
 					if err != nil {
-						c.String(http.StatusInternalServerError, "Failed to get data from table %s: %s", tableName, err.Error())
+						c.String(http.StatusInternalServerError, "Failed to get data from table %s: %s", model.Table, err.Error())
 						return
 					}
-					defer rows.Close()
 
-					columns, err := rows.Columns()
-					if err != nil {
-						c.String(http.StatusInternalServerError, "Failed to get columns from table %s: %s", tableName, err.Error())
-						return
+					columns := model.Columns()
+					quoted := make([]string, len(columns))
+					for i, column := range columns {
+						quoted[i] = models.QuoteIdent(column)
 					}
 
-					for rows.Next() {
-						values := make([]interface{}, len(columns))
-						valuePtrs := make([]interface{}, len(columns))
-						for i := range columns {
-							valuePtrs[i] = &values[i]
-						}
-
-						if err := rows.Scan(valuePtrs...); err != nil {
-							c.String(http.StatusInternalServerError, "Failed to scan row: %s", err.Error())
-							return
-						}
-
+					for _, row := range rows {
 						var valueStrings []string
-						for _, val := range values {
-							if b, ok := val.([]byte); ok {
-								valueStrings = append(valueStrings, fmt.Sprintf("'%s'", string(b)))
-							} else {
-								valueStrings = append(valueStrings, fmt.Sprintf("'%v'", val))
-							}
+						for _, field := range model.Fields {
+							valueStrings = append(valueStrings, sqlLiteral(d, field, row[field.Column]))
 						}
-						buffer.WriteString(fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s);\n", tableName, strings.Join(columns, ", "), strings.Join(valueStrings, ", ")))
+						statement := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s);\n", model.QuotedTable(), strings.Join(quoted, ", "), strings.Join(valueStrings, ", "))
+						buffer.WriteString(dialect.Rebind(d, statement))
 					}
 				}
 
@@ -698,21 +498,14 @@ func Router() *gin.Engine {
 		})
 
 		admin.POST("/dashboard/export-schema", func(c *gin.Context) {
-			schemas := []interface{}{
-				User{},
-				Session{},
-				Account{},
-				Verification{},
-			}
-
 			var buffer bytes.Buffer
-			for _, schema := range schemas {
-				query, err := GenerateCreateTableSQL(schema) // Use the local function
+			for _, model := range models.All() {
+				statements, err := schemaStatements(model)
 				if err != nil {
 					c.String(http.StatusInternalServerError, "Failed to generate schema: %s", err.Error())
 					return
 				}
-				buffer.WriteString(query)
+				buffer.WriteString(strings.Join(rebound(statements), "\n"))
 				buffer.WriteString("\n\n")
 			}
 
@@ -731,55 +524,162 @@ func Router() *gin.Engine {
 			c.Redirect(http.StatusFound, "/admin/dashboard")
 		})
 
-		admin.POST("/dashboard/get-data", func(c *gin.Context) {
-			model := c.PostForm("model")
-			if model == "" {
-				c.String(http.StatusBadRequest, "Table name is required")
+		admin.GET("/schema", func(c *gin.Context) {
+			connected, reports, err := schemaReport(c.Request.Context())
+			if err != nil {
+				c.String(http.StatusInternalServerError, "Failed to inspect schema: %s", err.Error())
+				return
+			}
+
+			c.HTML(http.StatusOK, "schema.html", gin.H{
+				"Title":     "Schema",
+				"Connected": connected,
+				"Models":    reports,
+			})
+		})
+
+		admin.GET("/pool", func(c *gin.Context) {
+			c.HTML(http.StatusOK, "pool.html", gin.H{
+				"Title": "Connection Pool",
+				"Pool":  poolReport(),
+			})
+		})
+
+		admin.GET("/pool/stats", func(c *gin.Context) {
+			c.JSON(http.StatusOK, poolReport())
+		})
+
+		admin.GET("/docs", func(c *gin.Context) {
+			documented := docs.Documented(models.All())
+			c.HTML(http.StatusOK, "docs.html", gin.H{
+				"Title":   "Schema Documentation",
+				"Mermaid": docs.Mermaid(db.Dialect(), documented),
+				"Tables":  docs.Tables(db.Dialect(), documented),
+			})
+		})
+
+		admin.GET("/docs/schema.md", func(c *gin.Context) {
+			c.Header("Content-Disposition", "attachment; filename=schema.md")
+			c.Data(http.StatusOK, "text/markdown; charset=utf-8", []byte(docs.Markdown(db.Dialect(), docs.Documented(models.All()))))
+		})
+
+		admin.GET("/migrations", func(c *gin.Context) {
+			db.Connect()
+
+			statuses, err := migrationRunner().Status(c.Request.Context())
+			if err != nil {
+				c.String(http.StatusInternalServerError, "Failed to read migrations: %s", err.Error())
+				return
+			}
+			changes, err := schemaDiff(c.Request.Context())
+			if err != nil {
+				c.String(http.StatusInternalServerError, "Failed to diff schema: %s", err.Error())
+				return
+			}
+
+			c.HTML(http.StatusOK, "migrations.html", gin.H{
+				"Title":      "Migrations",
+				"Migrations": statuses,
+				"Changes":    changes,
+			})
+		})
+
+		admin.POST("/migrations/up", func(c *gin.Context) {
+			db.Connect()
+			applied, err := migrationRunner().Up(c.Request.Context())
+			for _, migration := range applied {
+				u.InfoF("Applied migration %d %s\n", migration.Version, migration.Name)
+			}
+			if err != nil {
+				c.String(http.StatusInternalServerError, "Migration failed: %s", err.Error())
 				return
 			}
+			c.Redirect(http.StatusFound, "/admin/migrations")
+		})
 
+		admin.POST("/migrations/down", func(c *gin.Context) {
 			db.Connect()
+			reverted, err := migrationRunner().Down(c.Request.Context(), 1)
+			for _, migration := range reverted {
+				u.InfoF("Reverted migration %d %s\n", migration.Version, migration.Name)
+			}
+			if err != nil {
+				c.String(http.StatusInternalServerError, "Migration failed: %s", err.Error())
+				return
+			}
+			c.Redirect(http.StatusFound, "/admin/migrations")
+		})
 
-			sqlQuery := fmt.Sprintf("SELECT * FROM \"%s\"", model)
+		admin.POST("/dashboard/restore", func(c *gin.Context) {
+			model, ok := models.Lookup(c.PostForm("model"))
+			id := c.PostForm("id")
+			if !ok || !model.Options.SoftDelete || id == "" {
+				c.String(http.StatusBadRequest, "A soft-delete model and row id are required")
+				return
+			}
 
-			rows, err := db.Query(sqlQuery)
+			restored, err := store.UpdateMany(c.Request.Context(), model,
+				[]Where{{Field: "id", Value: id}},
+				storage.Row{models.DeletedAtColumn: nil},
+				storage.Mutation{},
+			)
 			if err != nil {
-				c.String(http.StatusInternalServerError, "Failed to get data: %s", err.Error())
+				c.String(http.StatusInternalServerError, "Failed to restore row: %s", err.Error())
+				return
+			}
+			if restored == 0 {
+				c.String(http.StatusNotFound, "No row with id %s in %s", id, model.Name)
+				return
+			}
+			c.Redirect(http.StatusFound, "/admin/dashboard")
+		})
+
+		admin.POST("/dashboard/purge", func(c *gin.Context) {
+			model, ok := models.Lookup(c.PostForm("model"))
+			if !ok || !model.Options.SoftDelete {
+				c.String(http.StatusBadRequest, "A soft-delete model is required")
 				return
 			}
-			defer rows.Close()
 
-			columns, err := rows.Columns()
+			// Rows deleted longer than the retention period ago
+			purged, err := store.DeleteMany(c.Request.Context(), model,
+				[]Where{{Field: models.DeletedAtColumn, Operator: "lt", Value: time.Now().Add(-SoftDeleteRetention)}},
+				storage.Mutation{Permanent: true},
+			)
 			if err != nil {
-				c.String(http.StatusInternalServerError, "Failed to get columns: %s", err.Error())
+				c.String(http.StatusInternalServerError, "Failed to purge rows: %s", err.Error())
 				return
 			}
+			u.InfoF("Purged %d soft-deleted rows from %s\n", purged, model.Name)
+			c.Redirect(http.StatusFound, "/admin/dashboard")
+		})
 
-			var results [][]interface{}
-			for rows.Next() {
-				values := make([]interface{}, len(columns))
-				valuePtrs := make([]interface{}, len(columns))
-				for i := range columns {
-					valuePtrs[i] = &values[i]
-				}
+		admin.POST("/dashboard/get-data", func(c *gin.Context) {
+			model, ok := models.Lookup(c.PostForm("model"))
+			if !ok {
+				c.String(http.StatusBadRequest, "Unknown model")
+				return
+			}
 
-				if err := rows.Scan(valuePtrs...); err != nil {
-					c.String(http.StatusInternalServerError, "Failed to scan row: %s", err.Error())
-					return
-				}
+			rows, err := store.FindMany(c.Request.Context(), model, storage.Query{IncludeDeleted: true})
+			if err != nil {
+				c.String(http.StatusInternalServerError, "Failed to get data: %s", err.Error())
+				return
+			}
 
-				// Convert byte slices to strings for display
-				for i, val := range values {
-					if b, ok := val.([]byte); ok {
-						values[i] = string(b)
-					}
+			columns := model.Columns()
+			var results [][]interface{}
+			for _, row := range rows {
+				values := make([]interface{}, len(columns))
+				for i, column := range columns {
+					values[i] = row[column]
 				}
 				results = append(results, values)
 			}
 
-			c.HTML(http.StatusOK, "admin/data.html", gin.H{
+			c.HTML(http.StatusOK, "data.html", gin.H{
 				"Title":   "Table Data",
-				"Model":   model,
+				"Model":   model.Name,
 				"Columns": columns,
 				"Rows":    results,
 			})
@@ -790,28 +690,91 @@ func Router() *gin.Engine {
 }
 
 func main() {
-	db = s.Database{}
-	db.Config = s.DatabaseConfig{}
-	db.Config.Host = "127.0.0.1"
-	db.Config.Port = 5432
-	db.Config.User = "postgres"
-	db.Config.Password = "postgres"
-	db.Config.Database = "postgres"
-	db.Config.SSL = "disable"
-	db.Config.Driver = s.Driver(s.POSTGRESQL)
-
-	db.Connect()
-	defer db.Close()
+	loaded, args, err := config.Load(os.Args[1:], os.Getenv)
+	if err != nil {
+		u.ErrorF("Invalid configuration:\t%s\n", err.Error())
+		os.Exit(2)
+	}
+	settings = loaded
+
+	var replicas []s.DatabaseConfig
+	for _, replica := range settings.Database.Replicas {
+		// Validated with the rest of the configuration.
+		parsed, _ := s.ParseDSN(replica)
+		replicas = append(replicas, parsed)
+	}
+
+	db.Configure(s.DatabaseConfig{
+		Host:            settings.Database.Host,
+		Port:            settings.Database.Port,
+		User:            settings.Database.User,
+		Password:        settings.Database.Password,
+		Database:        settings.Database.Name,
+		SSL:             settings.Database.SSLMode,
+		SSLRootCert:     settings.Database.SSLRootCert,
+		SSLCert:         settings.Database.SSLCert,
+		SSLKey:          settings.Database.SSLKey,
+		ApplicationName: settings.Database.ApplicationName,
+		SearchPath:      settings.Database.SearchPath,
+		Params:          settings.Database.Params,
+		Driver:          settings.Database.Driver,
+		Pool: s.PoolConfig{
+			MaxOpenConns:    settings.Database.Pool.MaxOpenConns,
+			MaxIdleConns:    settings.Database.Pool.MaxIdleConns,
+			ConnMaxLifetime: time.Duration(settings.Database.Pool.ConnMaxLifetime),
+			ConnMaxIdleTime: time.Duration(settings.Database.Pool.ConnMaxIdleTime),
+		},
+		Replicas: replicas,
+	})
+
+	DefaultMaxAffectedRows = settings.Features.MaxAffectedRows
+	SoftDeleteRetention = time.Duration(settings.Features.SoftDeleteRetention)
+	IdempotencyTTL = time.Duration(settings.Features.IdempotencyTTL)
+
+	if err := enablePlugins(settings.Features.Plugins); err != nil {
+		u.ErrorF("Failed to enable plugins:\t%s\n", err.Error())
+		os.Exit(1)
+	}
+
+	if len(args) > 0 {
+		switch args[0] {
+		case "migrate":
+			os.Exit(runMigrateCommand(args[1:]))
+		case "generate-models":
+			os.Exit(runGenerateModelsCommand(args[1:]))
+		case "docs":
+			os.Exit(runDocsCommand(args[1:]))
+		}
+	}
+	// Only for the server, the commands above write their results to stdout.
+	u.InfoF("Effective configuration:\n%s", settings)
+
+	if settings.Features.Storage == "memory" {
+		store = storage.NewMemory()
+	} else {
+		// Also when the database only comes up later, or is switched.
+		db.OnConnect(func() {
+			if err := loadDynamicModels(); err != nil {
+				u.ErrorF("Failed to load models from /create-schema:\t%s\n", err.Error())
+			}
+		})
+		db.Connect()
+		defer db.Close()
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go PurgeIdempotencyKeys(time.Hour, stop)
 
 	router := Router()
 
 	srv := &http.Server{
-		Addr:              ":8080",
+		Addr:              settings.Server.Address,
 		Handler:           router,
-		ReadHeaderTimeout: 5 * time.Second,
-		WriteTimeout:      30 * time.Second,
-		ReadTimeout:       60 * time.Second,
-		IdleTimeout:       15 * time.Minute,
+		ReadHeaderTimeout: time.Duration(settings.Server.ReadHeaderTimeout),
+		WriteTimeout:      time.Duration(settings.Server.WriteTimeout),
+		ReadTimeout:       time.Duration(settings.Server.ReadTimeout),
+		IdleTimeout:       time.Duration(settings.Server.IdleTimeout),
 	}
 
 	if err := srv.ListenAndServe(); err != nil {
@@ -0,0 +1,217 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"hack/backend/dialect"
+)
+
+// Table records which migrations have been applied.
+const Table = "schema_migrations"
+
+// LockName is the lock held while migrating, so two instances starting at
+// once do not both run the same migration.
+const LockName = "schema_migrations"
+
+// Tx is the transaction a migration runs in.
+type Tx struct {
+	*sql.Tx
+	Dialect dialect.Dialect
+}
+
+type Func func(ctx context.Context, tx *Tx) error
+
+// Migration is one ordered schema change. Down may be nil for migrations
+// that cannot be reverted.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      Func
+	Down    Func
+}
+
+// Status is a migration together with when it was applied, if it was.
+type Status struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"appliedAt"`
+}
+
+//go:embed sql/*.sql
+var sqlFiles embed.FS
+
+var registered = map[int64]*Migration{}
+
+// Register adds a Go migration. It panics on a duplicate version, as that
+// is a programming error.
+func Register(migration Migration) {
+	if migration.Version <= 0 || migration.Up == nil {
+		panic(fmt.Sprintf("migrations: invalid migration %d %q", migration.Version, migration.Name))
+	}
+	if existing, ok := registered[migration.Version]; ok {
+		panic(fmt.Sprintf("migrations: version %d registered twice (%s, %s)", migration.Version, existing.Name, migration.Name))
+	}
+	registered[migration.Version] = &migration
+}
+
+// All returns every migration ordered by version.
+func All() []*Migration {
+	all := make([]*Migration, 0, len(registered))
+	for _, migration := range registered {
+		all = append(all, migration)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Version < all[j].Version })
+	return all
+}
+
+func init() {
+	if err := loadSQL(sqlFiles, "sql"); err != nil {
+		panic(err)
+	}
+}
+
+// loadSQL registers migrations from files named <version>_<name>.up.sql and
+// <version>_<name>.down.sql. A file named <version>_<name>.<dialect>.up.sql
+// replaces the plain one on that dialect.
+func loadSQL(files fs.FS, dir string) error {
+	entries, err := fs.ReadDir(files, dir)
+	if err != nil {
+		return err
+	}
+
+	type pair struct {
+		name     string
+		up, down map[string]string // dialect name, "" for any -> statements
+	}
+	pairs := map[int64]*pair{}
+
+	for _, entry := range entries {
+		file := entry.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(file, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(file, ".down.sql"):
+			direction = "down"
+		default:
+			return fmt.Errorf("migrations: %s is neither .up.sql nor .down.sql", file)
+		}
+
+		base := strings.TrimSuffix(file, "."+direction+".sql")
+		dialectName := ""
+		if dot := strings.LastIndex(base, "."); dot >= 0 {
+			if _, ok := dialect.Get(base[dot+1:]); !ok {
+				return fmt.Errorf("migrations: %s names unknown dialect '%s'", file, base[dot+1:])
+			}
+			base, dialectName = base[:dot], base[dot+1:]
+		}
+		prefix, name, ok := strings.Cut(base, "_")
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if !ok || err != nil {
+			return fmt.Errorf("migrations: %s does not start with a version number", file)
+		}
+
+		contents, err := fs.ReadFile(files, path.Join(dir, file))
+		if err != nil {
+			return err
+		}
+
+		p, ok := pairs[version]
+		if !ok {
+			p = &pair{name: name, up: map[string]string{}, down: map[string]string{}}
+			pairs[version] = p
+		}
+		if direction == "up" {
+			p.up[dialectName] = string(contents)
+		} else {
+			p.down[dialectName] = string(contents)
+		}
+	}
+
+	for version, p := range pairs {
+		if p.up[""] == "" {
+			return fmt.Errorf("migrations: version %d has no up file", version)
+		}
+		migration := Migration{Version: version, Name: p.name, Up: execSQL(p.up)}
+		if p.down[""] != "" {
+			migration.Down = execSQL(p.down)
+		}
+		Register(migration)
+	}
+	return nil
+}
+
+// ExecContext rebinds canonical SQL, with ? placeholders and double-quoted
+// identifiers, for the dialect.
+func (this *Tx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return this.Tx.ExecContext(ctx, dialect.Rebind(this.Dialect, query), dialect.Args(this.Dialect, args)...)
+}
+
+// execSQL runs the statements written for the transaction's dialect, or
+// the plain ones, one at a time: the MySQL driver takes a single statement
+// per call.
+func execSQL(byDialect map[string]string) Func {
+	return func(ctx context.Context, tx *Tx) error {
+		statements, ok := byDialect[tx.Dialect.Name()]
+		if !ok {
+			statements = byDialect[""]
+		}
+		for _, statement := range splitStatements(statements) {
+			if _, err := tx.ExecContext(ctx, statement); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// splitStatements splits a SQL file on the semicolons outside quotes and
+// comments, leaving out pieces that hold nothing but comments.
+func splitStatements(file string) []string {
+	var statements []string
+	start, empty := 0, true
+	for i := 0; i < len(file); i++ {
+		switch c := file[i]; {
+		case c == '\'' || c == '"' || c == '`':
+			// A doubled quote inside reads as the end and start of two quotes.
+			if end := strings.IndexByte(file[i+1:], c); end >= 0 {
+				i += end + 1
+			} else {
+				i = len(file)
+			}
+			empty = false
+		case c == '-' && strings.HasPrefix(file[i:], "--"):
+			if end := strings.IndexByte(file[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(file)
+			}
+		case c == '/' && strings.HasPrefix(file[i:], "/*"):
+			if end := strings.Index(file[i+2:], "*/"); end >= 0 {
+				i += end + 3
+			} else {
+				i = len(file)
+			}
+		case c == ';':
+			if !empty {
+				statements = append(statements, strings.TrimSpace(file[start:i]))
+			}
+			start, empty = i+1, true
+		case c != ' ' && c != '\t' && c != '\n' && c != '\r':
+			empty = false
+		}
+	}
+	if !empty {
+		statements = append(statements, strings.TrimSpace(file[start:]))
+	}
+	return statements
+}
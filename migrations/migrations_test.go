@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	file := `-- Sessions; by expiry.
+CREATE INDEX "a" ON "session" ("expiresAt");
+/* a; b */
+INSERT INTO "note" ("text") VALUES ('it''s; fine'), ("x;y"), (` + "`a;b`" + `);
+
+-- trailing comment;
+UPDATE "note" SET "text" = ''`
+
+	expected := []string{
+		`-- Sessions; by expiry.
+CREATE INDEX "a" ON "session" ("expiresAt")`,
+		`/* a; b */
+INSERT INTO "note" ("text") VALUES ('it''s; fine'), ("x;y"), (` + "`a;b`" + `)`,
+		`-- trailing comment;
+UPDATE "note" SET "text" = ''`,
+	}
+	if statements := splitStatements(file); !reflect.DeepEqual(statements, expected) {
+		t.Errorf("expected %q, got %q", expected, statements)
+	}
+
+	if statements := splitStatements("-- nothing;\n  ;\n"); len(statements) != 0 {
+		t.Errorf("expected no statements, got %q", statements)
+	}
+}
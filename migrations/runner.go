@@ -0,0 +1,168 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"time"
+
+	"hack/backend/dialect"
+	"hack/backend/models"
+	s "hack/backend/server"
+)
+
+// Connector hands out a dedicated connection; *server.Database satisfies it.
+type Connector interface {
+	Conn(ctx context.Context) (*s.Conn, error)
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Runner applies and reverts migrations against one database.
+type Runner struct {
+	DB      Connector
+	Dialect dialect.Dialect
+}
+
+// withLock runs fn on a single connection holding the migration lock. The
+// lock is session scoped, so everything must go through conn.
+func (this *Runner) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := this.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if acquire, release := this.Dialect.Lock(LockName); acquire != "" {
+		if _, err := conn.ExecContext(ctx, acquire); err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		defer conn.ExecContext(context.Background(), release)
+	}
+
+	if _, err := conn.ExecContext(ctx, dialect.Rebind(this.Dialect, fmt.Sprintf(
+		`CREATE TABLE IF NOT EXISTS %s ("version" BIGINT PRIMARY KEY, "name" TEXT NOT NULL, "appliedAt" %s NOT NULL)`,
+		models.QuoteIdent(Table), this.Dialect.ColumnType(timeType),
+	))); err != nil {
+		return fmt.Errorf("failed to create %s: %w", Table, err)
+	}
+
+	return fn(conn.Conn)
+}
+
+func (this *Runner) applied(ctx context.Context, conn *sql.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, dialect.Rebind(this.Dialect, fmt.Sprintf(`SELECT "version", "appliedAt" FROM %s`, models.QuoteIdent(Table))))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := map[int64]time.Time{}
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		versions[version] = appliedAt
+	}
+	return versions, rows.Err()
+}
+
+// run executes one migration step and records it in the same transaction.
+func (this *Runner) run(ctx context.Context, conn *sql.Conn, migration *Migration, up bool) error {
+	sqlTx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer sqlTx.Rollback()
+	tx := &Tx{Tx: sqlTx, Dialect: this.Dialect}
+
+	if up {
+		if err := migration.Up(ctx, tx); err != nil {
+			return fmt.Errorf("migration %d %s failed: %w", migration.Version, migration.Name, err)
+		}
+		_, err = tx.ExecContext(ctx, fmt.Sprintf(`INSERT INTO %s ("version", "name", "appliedAt") VALUES (?, ?, ?)`, models.QuoteIdent(Table)),
+			migration.Version, migration.Name, time.Now())
+	} else {
+		if err := migration.Down(ctx, tx); err != nil {
+			return fmt.Errorf("reverting migration %d %s failed: %w", migration.Version, migration.Name, err)
+		}
+		_, err = tx.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE "version" = ?`, models.QuoteIdent(Table)), migration.Version)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Up applies every pending migration in version order and returns the ones
+// it applied.
+func (this *Runner) Up(ctx context.Context) ([]*Migration, error) {
+	var done []*Migration
+	err := this.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := this.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range All() {
+			if _, ok := versions[migration.Version]; ok {
+				continue
+			}
+			if err := this.run(ctx, conn, migration, true); err != nil {
+				return err
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down reverts the last steps applied migrations, newest first.
+func (this *Runner) Down(ctx context.Context, steps int) ([]*Migration, error) {
+	var done []*Migration
+	err := this.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := this.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		all := All()
+		for i := len(all) - 1; i >= 0 && len(done) < steps; i-- {
+			migration := all[i]
+			if _, ok := versions[migration.Version]; !ok {
+				continue
+			}
+			if migration.Down == nil {
+				return fmt.Errorf("migration %d %s cannot be reverted", migration.Version, migration.Name)
+			}
+			if err := this.run(ctx, conn, migration, false); err != nil {
+				return err
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Status lists every known migration and when it was applied.
+func (this *Runner) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := this.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := this.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range All() {
+			status := Status{Version: migration.Version, Name: migration.Name}
+			if appliedAt, ok := versions[migration.Version]; ok {
+				status.AppliedAt = &appliedAt
+			}
+			statuses = append(statuses, status)
+		}
+		return nil
+	})
+	return statuses, err
+}
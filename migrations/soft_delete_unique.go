@@ -0,0 +1,89 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"hack/backend/models"
+)
+
+// liveUniqueKeys are the unique keys of the soft-delete models as of this
+// migration: index name, table and columns.
+var liveUniqueKeys = []struct {
+	name, table string
+	columns     []string
+	mysqlName   string // of the index an inline UNIQUE got on MySQL
+}{
+	{"user_email_key", "user", []string{"email"}, "email"},
+	{"account_provider_account_key", "account", []string{"accountId", "providerId"}, "account_provider_account_key"},
+}
+
+// Unique keys of soft-delete models leave out soft-deleted rows, so a
+// deleted user's email can sign up again. SQLite cannot drop a UNIQUE
+// constraint without rebuilding the table, which would cascade into the
+// sessions and accounts, so its databases created before keep the old
+// constraint next to the new index.
+func init() {
+	Register(Migration{
+		Version: 3,
+		Name:    "soft_delete_unique",
+		Up: func(ctx context.Context, tx *Tx) error {
+			for _, key := range liveUniqueKeys {
+				var statements []string
+				switch tx.Dialect.Name() {
+				case "mysql":
+					indexes := []string{key.name}
+					if key.mysqlName != key.name {
+						indexes = append(indexes, key.mysqlName)
+					}
+					for _, index := range indexes {
+						exists, err := mysqlIndexExists(ctx, tx, key.table, index)
+						if err != nil {
+							return err
+						}
+						if exists {
+							statements = append(statements, fmt.Sprintf("DROP INDEX %s ON %s", models.QuoteIdent(index), models.QuoteIdent(key.table)))
+						}
+					}
+					parts := make([]string, len(key.columns))
+					for i, column := range key.columns {
+						parts[i] = fmt.Sprintf(`(CASE WHEN "deletedAt" IS NULL THEN %s END)`, models.QuoteIdent(column))
+					}
+					statements = append(statements, fmt.Sprintf("CREATE UNIQUE INDEX %s ON %s (%s)", models.QuoteIdent(key.name), models.QuoteIdent(key.table), strings.Join(parts, ", ")))
+				case "postgres":
+					statements = append(statements, fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT IF EXISTS %s", models.QuoteIdent(key.table), models.QuoteIdent(key.name)))
+					fallthrough
+				default:
+					columns := make([]string, len(key.columns))
+					for i, column := range key.columns {
+						columns[i] = models.QuoteIdent(column)
+					}
+					statements = append(statements, fmt.Sprintf(
+						`CREATE UNIQUE INDEX IF NOT EXISTS %s ON %s (%s) WHERE "deletedAt" IS NULL`,
+						models.QuoteIdent(key.name), models.QuoteIdent(key.table), strings.Join(columns, ", "),
+					))
+				}
+
+				for _, statement := range statements {
+					if _, err := tx.ExecContext(ctx, statement); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		},
+	})
+}
+
+// mysqlIndexExists checks for an index, as MySQL has no DROP INDEX IF
+// EXISTS. The base schema has the inline one, tables made by createSchema
+// since already have the new one.
+func mysqlIndexExists(ctx context.Context, tx *Tx, table string, index string) (bool, error) {
+	var count int
+	err := tx.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND INDEX_NAME = ?",
+		table, index,
+	).Scan(&count)
+	return count > 0, err
+}
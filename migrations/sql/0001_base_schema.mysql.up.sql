@@ -0,0 +1,10 @@
+-- The base schema as it stood when migrations were introduced. It only
+-- creates missing tables, so databases set up with createSchema before
+-- migrations existed adopt it cleanly; later changes are migrations of
+-- their own. Frozen: never edit, add a migration instead.
+-- MySQL declares indexes with the table, it has no CREATE INDEX IF NOT EXISTS.
+CREATE TABLE IF NOT EXISTS "user" ("id" VARCHAR(191), "name" TEXT NOT NULL, "email" VARCHAR(191) NOT NULL UNIQUE, "emailVerified" BOOLEAN NOT NULL DEFAULT false, "image" TEXT, "createdAt" DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3), "updatedAt" DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3), "deletedAt" DATETIME(3), PRIMARY KEY ("id"));
+CREATE TABLE IF NOT EXISTS "session" ("id" VARCHAR(191), "userId" VARCHAR(191) NOT NULL, "token" VARCHAR(191) NOT NULL UNIQUE, "expiresAt" DATETIME(3) NOT NULL, "ipAddress" TEXT, "userAgent" TEXT, "createdAt" DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3), "updatedAt" DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3), PRIMARY KEY ("id"), FOREIGN KEY ("userId") REFERENCES "user" ("id") ON DELETE CASCADE, INDEX "session_userId_idx" ("userId"));
+CREATE TABLE IF NOT EXISTS "account" ("id" VARCHAR(191), "userId" VARCHAR(191) NOT NULL, "accountId" VARCHAR(191) NOT NULL, "providerId" VARCHAR(191) NOT NULL, "accessToken" TEXT, "refreshToken" TEXT, "accessTokenExpiresAt" DATETIME(3), "refreshTokenExpiresAt" DATETIME(3), "scope" TEXT, "idToken" TEXT, "password" TEXT, "createdAt" DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3), "updatedAt" DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3), "deletedAt" DATETIME(3), PRIMARY KEY ("id"), CONSTRAINT "account_provider_account_key" UNIQUE ("accountId", "providerId"), FOREIGN KEY ("userId") REFERENCES "user" ("id") ON DELETE CASCADE, INDEX "account_userId_idx" ("userId"));
+CREATE TABLE IF NOT EXISTS "verification" ("id" VARCHAR(191), "identifier" VARCHAR(191) NOT NULL, "value" TEXT NOT NULL, "expiresAt" DATETIME(3) NOT NULL, "createdAt" DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3), "updatedAt" DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3), PRIMARY KEY ("id"), INDEX "verification_identifier_idx" ("identifier"));
+CREATE TABLE IF NOT EXISTS "idempotencykey" ("key" VARCHAR(191), "fingerprint" TEXT NOT NULL, "status" BIGINT NOT NULL, "contentType" TEXT NOT NULL, "body" LONGBLOB NOT NULL, "createdAt" DATETIME(3) NOT NULL, "expiresAt" DATETIME(3) NOT NULL, PRIMARY KEY ("key"), INDEX "idempotencykey_expiresAt_idx" ("expiresAt"));
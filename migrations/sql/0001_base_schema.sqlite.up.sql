@@ -0,0 +1,13 @@
+-- The base schema as it stood when migrations were introduced. It only
+-- creates missing tables, so databases set up with createSchema before
+-- migrations existed adopt it cleanly; later changes are migrations of
+-- their own. Frozen: never edit, add a migration instead.
+CREATE TABLE IF NOT EXISTS "user" ("id" TEXT, "name" TEXT NOT NULL, "email" TEXT NOT NULL UNIQUE, "emailVerified" BOOLEAN NOT NULL DEFAULT false, "image" TEXT, "createdAt" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, "updatedAt" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, "deletedAt" TIMESTAMP, PRIMARY KEY ("id"));
+CREATE TABLE IF NOT EXISTS "session" ("id" TEXT, "userId" TEXT NOT NULL REFERENCES "user" ("id") ON DELETE CASCADE, "token" TEXT NOT NULL UNIQUE, "expiresAt" TIMESTAMP NOT NULL, "ipAddress" TEXT, "userAgent" TEXT, "createdAt" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, "updatedAt" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "session_userId_idx" ON "session" ("userId");
+CREATE TABLE IF NOT EXISTS "account" ("id" TEXT, "userId" TEXT NOT NULL REFERENCES "user" ("id") ON DELETE CASCADE, "accountId" TEXT NOT NULL, "providerId" TEXT NOT NULL, "accessToken" TEXT, "refreshToken" TEXT, "accessTokenExpiresAt" TIMESTAMP, "refreshTokenExpiresAt" TIMESTAMP, "scope" TEXT, "idToken" TEXT, "password" TEXT, "createdAt" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, "updatedAt" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, "deletedAt" TIMESTAMP, PRIMARY KEY ("id"), CONSTRAINT "account_provider_account_key" UNIQUE ("accountId", "providerId"));
+CREATE INDEX IF NOT EXISTS "account_userId_idx" ON "account" ("userId");
+CREATE TABLE IF NOT EXISTS "verification" ("id" TEXT, "identifier" TEXT NOT NULL, "value" TEXT NOT NULL, "expiresAt" TIMESTAMP NOT NULL, "createdAt" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, "updatedAt" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "verification_identifier_idx" ON "verification" ("identifier");
+CREATE TABLE IF NOT EXISTS "idempotencykey" ("key" TEXT, "fingerprint" TEXT NOT NULL, "status" INTEGER NOT NULL, "contentType" TEXT NOT NULL, "body" BLOB NOT NULL, "createdAt" TIMESTAMP NOT NULL, "expiresAt" TIMESTAMP NOT NULL, PRIMARY KEY ("key"));
+CREATE INDEX IF NOT EXISTS "idempotencykey_expiresAt_idx" ON "idempotencykey" ("expiresAt");
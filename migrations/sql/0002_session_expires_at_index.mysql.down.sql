@@ -0,0 +1 @@
+DROP INDEX "session_expiresAt_idx" ON "session";
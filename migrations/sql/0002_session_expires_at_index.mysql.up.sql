@@ -0,0 +1,2 @@
+-- MySQL has no CREATE INDEX IF NOT EXISTS; the migration runs once.
+CREATE INDEX "session_expiresAt_idx" ON "session" ("expiresAt");
@@ -0,0 +1,2 @@
+-- Expired session cleanup scans by expiresAt.
+CREATE INDEX IF NOT EXISTS "session_expiresAt_idx" ON "session" ("expiresAt");
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"hack/backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// resolveModel looks up the model a request targets. Unknown and internal
+// models are rejected before any SQL is built.
+func resolveModel(c *gin.Context, name string) (*models.Model, bool) {
+	model, err := lookupModel(name)
+	if err != nil {
+		AbortRespond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return model, true
+}
+
+// lookupModel finds a model the adapter API may use.
+func lookupModel(name string) (*models.Model, error) {
+	model, ok := models.Lookup(name)
+	if !ok || model.Options.Internal {
+		return nil, fmt.Errorf("Unknown model '%s'", name)
+	}
+	return model, nil
+}
+
+// coerceData checks the columns of a create or update payload against the
+// model and converts the values to the field types.
+func coerceData(model *models.Model, data interface{}) (map[string]interface{}, error) {
+	values, ok := data.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected an object of field values")
+	}
+
+	coerced := make(map[string]interface{}, len(values))
+	for column, value := range values {
+		field, ok := model.Field(column)
+		if !ok {
+			return nil, fmt.Errorf("unknown field '%s' on model '%s'", column, model.Name)
+		}
+		converted, err := field.Coerce(value)
+		if err != nil {
+			return nil, err
+		}
+		coerced[column] = converted
+	}
+	return coerced, nil
+}
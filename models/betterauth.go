@@ -0,0 +1,227 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BetterAuthReference is a foreign key in a Better Auth schema.
+type BetterAuthReference struct {
+	Model    string `json:"model"`
+	Field    string `json:"field"`
+	OnDelete string `json:"onDelete,omitempty"`
+}
+
+// BetterAuthField is one field of a table in Better Auth's schema format.
+// Type is a type name ("string", "number", "boolean", "date", "json",
+// "string[]", "number[]") or a list of allowed string values.
+type BetterAuthField struct {
+	Type         interface{}          `json:"type"`
+	Required     *bool                `json:"required,omitempty"`
+	Unique       bool                 `json:"unique,omitempty"`
+	BigInt       bool                 `json:"bigint,omitempty"`
+	Index        bool                 `json:"index,omitempty"`
+	References   *BetterAuthReference `json:"references,omitempty"`
+	DefaultValue interface{}          `json:"defaultValue,omitempty"`
+	FieldName    string               `json:"fieldName,omitempty"`
+}
+
+// BetterAuthTable is one table in Better Auth's schema format, as produced
+// by its CLI and plugins.
+type BetterAuthTable struct {
+	ModelName         string                     `json:"modelName"`
+	Fields            map[string]BetterAuthField `json:"fields"`
+	DisableMigrations bool                       `json:"disableMigrations,omitempty"`
+	Order             int                        `json:"order,omitempty"`
+}
+
+var (
+	interfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
+
+	betterAuthTypes = map[string]reflect.Type{
+		"string":   reflect.TypeOf(""),
+		"number":   reflect.TypeOf(int32(0)),
+		"boolean":  reflect.TypeOf(false),
+		"date":     timeType,
+		"json":     interfaceType,
+		"string[]": reflect.TypeOf([]string{}),
+		"number[]": reflect.TypeOf([]int32{}),
+	}
+)
+
+// SortBetterAuthTables orders table keys by their declared order, then by
+// key, so referenced tables come first.
+func SortBetterAuthTables(tables map[string]BetterAuthTable) []string {
+	keys := make([]string, 0, len(tables))
+	for key := range tables {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if tables[keys[i]].Order != tables[keys[j]].Order {
+			return tables[keys[i]].Order < tables[keys[j]].Order
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// FromBetterAuth builds a model from a Better Auth table. The model is named
+// after modelName, which is what Better Auth sends to the adapter; names maps
+// the schema keys used in references to model names. Better Auth leaves the
+// id out of the schema, so a text primary key is added when it is missing.
+func FromBetterAuth(key string, table BetterAuthTable, names map[string]string) (*Model, error) {
+	name := table.ModelName
+	if name == "" {
+		name = key
+	}
+	model := &Model{Name: name, Table: name}
+
+	if _, ok := table.Fields["id"]; !ok {
+		model.Fields = append(model.Fields, &Field{Column: "id", Type: reflect.TypeOf(""), PrimaryKey: true})
+	}
+
+	fieldKeys := make([]string, 0, len(table.Fields))
+	for fieldKey := range table.Fields {
+		fieldKeys = append(fieldKeys, fieldKey)
+	}
+	sort.Strings(fieldKeys)
+
+	for _, fieldKey := range fieldKeys {
+		spec := table.Fields[fieldKey]
+		field := &Field{
+			Column:     spec.FieldName,
+			PrimaryKey: fieldKey == "id",
+			Unique:     spec.Unique,
+			Index:      spec.Index,
+			// Better Auth treats fields as required unless told otherwise.
+			Nullable: spec.Required != nil && !*spec.Required,
+		}
+		if field.Column == "" {
+			field.Column = fieldKey
+		}
+
+		var err error
+		var allowed []string
+		if field.Type, allowed, err = betterAuthType(spec); err != nil {
+			return nil, fmt.Errorf("field '%s' of '%s': %w", fieldKey, key, err)
+		}
+
+		if spec.DefaultValue != nil {
+			if field.Default, err = defaultLiteral(field.Type, allowed, spec.DefaultValue); err != nil {
+				return nil, fmt.Errorf("field '%s' of '%s': %w", fieldKey, key, err)
+			}
+		}
+
+		if ref := spec.References; ref != nil {
+			target := ref.Model
+			if mapped, ok := names[target]; ok {
+				target = mapped
+			}
+			field.References = &Reference{Model: target, Column: ref.Field, OnDelete: strings.ToLower(ref.OnDelete)}
+		}
+
+		model.Fields = append(model.Fields, field)
+	}
+
+	return model, nil
+}
+
+// betterAuthType maps a field's type to a Go type. Enumerations come back as
+// strings along with their allowed values.
+func betterAuthType(spec BetterAuthField) (reflect.Type, []string, error) {
+	switch v := spec.Type.(type) {
+	case string:
+		if v == "number" && spec.BigInt {
+			return reflect.TypeOf(int64(0)), nil, nil
+		}
+		if t, ok := betterAuthTypes[v]; ok {
+			return t, nil, nil
+		}
+		return nil, nil, fmt.Errorf("unsupported type '%s'", v)
+	case []interface{}:
+		allowed := make([]string, len(v))
+		for i, value := range v {
+			s, ok := value.(string)
+			if !ok {
+				return nil, nil, fmt.Errorf("enumeration values must be strings")
+			}
+			allowed[i] = s
+		}
+		return reflect.TypeOf(""), allowed, nil
+	}
+	return nil, nil, fmt.Errorf("missing or invalid type")
+}
+
+// defaultLiteral renders a default value as a SQL literal after checking it
+// fits the field type. Only plain values are accepted, never SQL.
+func defaultLiteral(t reflect.Type, allowed []string, value interface{}) (string, error) {
+	switch t.Kind() {
+	case reflect.String:
+		s, ok := value.(string)
+		if !ok {
+			break
+		}
+		if allowed != nil && !containsString(allowed, s) {
+			return "", fmt.Errorf("default '%s' is not one of the allowed values", s)
+		}
+		return "'" + strings.ReplaceAll(s, "'", "''") + "'", nil
+	case reflect.Int32, reflect.Int64:
+		var n float64
+		switch v := value.(type) {
+		case float64:
+			n = v
+		case int64:
+			n = float64(v)
+		case uint64:
+			n = float64(v)
+		default:
+			return "", fmt.Errorf("default must be a number")
+		}
+		if n != math.Trunc(n) {
+			return "", fmt.Errorf("default must be a whole number")
+		}
+		return strconv.FormatInt(int64(n), 10), nil
+	case reflect.Bool:
+		if b, ok := value.(bool); ok {
+			return strings.ToUpper(strconv.FormatBool(b)), nil
+		}
+	case reflect.Struct:
+		if t == timeType {
+			if s, ok := value.(string); ok {
+				if parsed, err := time.Parse(time.RFC3339Nano, s); err == nil {
+					return "'" + parsed.UTC().Format(time.RFC3339Nano) + "'", nil
+				}
+			}
+		}
+	}
+	return "", fmt.Errorf("invalid default value %v", value)
+}
+
+func containsString(values []string, s string) bool {
+	for _, value := range values {
+		if value == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Extend returns a copy of model with the fields of incoming it does not
+// have yet, along with those new fields. Existing fields are never changed.
+func (this *Model) Extend(incoming *Model) (*Model, []*Field) {
+	var added []*Field
+	for _, field := range incoming.Fields {
+		if _, ok := this.Field(field.Column); !ok {
+			added = append(added, field)
+		}
+	}
+
+	extended := *this
+	extended.Fields = append(append([]*Field{}, this.Fields...), added...)
+	return &extended, added
+}
@@ -0,0 +1,204 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Coerce converts a decoded request value (JSON, MessagePack or CBOR) into
+// the Go type the field is declared with.
+func (this *Field) Coerce(value interface{}) (interface{}, error) {
+	if value == nil {
+		if !this.Nullable {
+			return nil, fmt.Errorf("field '%s' cannot be null", this.Column)
+		}
+		return nil, nil
+	}
+
+	t := this.Type
+	if t == timeType {
+		if parsed, ok := coerceTime(value); ok {
+			return parsed, nil
+		}
+		return nil, fmt.Errorf("invalid timestamp for field '%s'", this.Column)
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		switch v := value.(type) {
+		case string:
+			return v, nil
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+			return fmt.Sprint(v), nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		switch v := value.(type) {
+		case float64:
+			if v == math.Trunc(v) {
+				return int64(v), nil
+			}
+		case float32:
+			if float64(v) == math.Trunc(float64(v)) {
+				return int64(v), nil
+			}
+		case string:
+			if parsed, err := strconv.ParseInt(v, 10, 64); err == nil {
+				return parsed, nil
+			}
+		default:
+			rv := reflect.ValueOf(v)
+			switch rv.Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				return rv.Int(), nil
+			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+				if rv.Uint() <= math.MaxInt64 {
+					return int64(rv.Uint()), nil
+				}
+			}
+		}
+	case reflect.Float32, reflect.Float64:
+		switch v := value.(type) {
+		case float64:
+			return v, nil
+		case string:
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				return parsed, nil
+			}
+		default:
+			rv := reflect.ValueOf(v)
+			switch rv.Kind() {
+			case reflect.Float32:
+				return rv.Float(), nil
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				return float64(rv.Int()), nil
+			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+				return float64(rv.Uint()), nil
+			}
+		}
+	case reflect.Bool:
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			if parsed, err := strconv.ParseBool(v); err == nil {
+				return parsed, nil
+			}
+		}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			switch v := value.(type) {
+			case []byte:
+				return v, nil
+			case string:
+				if decoded, err := base64.StdEncoding.DecodeString(v); err == nil {
+					return decoded, nil
+				}
+			}
+			break
+		}
+		return this.coerceArray(value)
+	default:
+		return value, nil
+	}
+
+	return nil, fmt.Errorf("invalid value for field '%s'", this.Column)
+}
+
+// coerceArray converts a decoded list into a typed slice so the driver can
+// bind it as an array. Lists of objects are left alone for JSON columns.
+func (this *Field) coerceArray(value interface{}) (interface{}, error) {
+	items, ok := value.([]interface{})
+	elemType := this.Type.Elem()
+	switch elemType.Kind() {
+	case reflect.Map, reflect.Struct, reflect.Interface, reflect.Slice:
+		if elemType != timeType {
+			return value, nil
+		}
+	}
+	if !ok {
+		return value, nil
+	}
+
+	elem := &Field{Column: this.Column, Type: elemType}
+	coerced := reflect.MakeSlice(this.Type, 0, len(items))
+	for _, item := range items {
+		converted, err := elem.Coerce(item)
+		if err != nil {
+			return nil, err
+		}
+		coerced = reflect.Append(coerced, reflect.ValueOf(converted).Convert(elemType))
+	}
+	return coerced.Interface(), nil
+}
+
+// coerceTime accepts time values, RFC 3339 strings and Unix milliseconds.
+func coerceTime(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		if parsed, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return parsed, true
+		}
+		for _, layout := range []string{"2006-01-02 15:04:05.999999999-07:00", "2006-01-02 15:04:05"} {
+			if parsed, err := time.Parse(layout, v); err == nil {
+				return parsed, true
+			}
+		}
+	case float64:
+		return time.UnixMilli(int64(v)).UTC(), true
+	default:
+		// MessagePack and CBOR decode numbers to whichever kind fits.
+		rv := reflect.ValueOf(v)
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return time.UnixMilli(rv.Int()).UTC(), true
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if rv.Uint() <= math.MaxInt64 {
+				return time.UnixMilli(int64(rv.Uint())).UTC(), true
+			}
+		case reflect.Float32:
+			return time.UnixMilli(int64(rv.Float())).UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// FromDB converts a scanned column value back to the field type when the
+// driver has no native type for it, such as SQLite booleans stored as
+// integers or arrays stored as JSON text.
+func (this *Field) FromDB(value interface{}) interface{} {
+	if b, ok := value.([]byte); ok && !(this.Type.Kind() == reflect.Slice && this.Type.Elem().Kind() == reflect.Uint8) {
+		value = string(b)
+	}
+
+	switch v := value.(type) {
+	case int64:
+		if this.Type.Kind() == reflect.Bool {
+			return v != 0
+		}
+	case string:
+		switch {
+		case this.Type == timeType:
+			if parsed, ok := coerceTime(v); ok {
+				return parsed
+			}
+		case this.Type.Kind() == reflect.Slice && this.Type.Elem().Kind() != reflect.Uint8:
+			var items []interface{}
+			if json.Unmarshal([]byte(v), &items) == nil {
+				return items
+			}
+		}
+	}
+	return value
+}
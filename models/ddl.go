@@ -0,0 +1,237 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+
+	"hack/backend/dialect"
+)
+
+var onDeleteActions = map[string]string{
+	"":            "",
+	"cascade":     "CASCADE",
+	"restrict":    "RESTRICT",
+	"set null":    "SET NULL",
+	"set default": "SET DEFAULT",
+	"no action":   "NO ACTION",
+}
+
+// ColumnType is the field's SQL type in dialect d, honoring an explicit
+// sqltype override.
+func (this *Field) ColumnType(d dialect.Dialect) string {
+	if this.SQLType != "" {
+		return this.SQLType
+	}
+	return d.ColumnType(this.Type)
+}
+
+// Table resolves the physical table behind a reference.
+func (this *Reference) Table() string {
+	if target, ok := Lookup(this.Model); ok {
+		return target.Table
+	}
+	return this.Model
+}
+
+// ColumnDefinition renders a column of model with its inline constraints.
+func ColumnDefinition(d dialect.Dialect, model *Model, field *Field) string {
+	columnType, def := field.ColumnType(d), field.Default
+	if adjuster, ok := d.(dialect.ColumnAdjuster); ok && field.SQLType == "" {
+		keyed := field.PrimaryKey || field.Unique || field.Index || field.References != nil || len(field.UniqueGroups) > 0
+		columnType, def = adjuster.AdjustColumn(field.Type, columnType, def, keyed)
+	}
+	definition := fmt.Sprintf("%s %s", QuoteIdent(field.Column), columnType)
+
+	if !field.Nullable && !field.PrimaryKey {
+		definition += " NOT NULL"
+	}
+	if field.Unique && !field.PrimaryKey && !model.Options.SoftDelete {
+		definition += " UNIQUE"
+	}
+	if clause := d.AutoIncrement(); field.AutoIncrement && clause != "" {
+		definition += " " + clause
+	} else if def != "" {
+		definition += " DEFAULT " + def
+	}
+	if field.References != nil && d.SupportsInlineReferences() {
+		definition += " " + referenceClause(field.References)
+	}
+	return definition
+}
+
+func referenceClause(ref *Reference) string {
+	clause := fmt.Sprintf("REFERENCES %s (%s)", QuoteIdent(ref.Table()), QuoteIdent(ref.Column))
+	if action := onDeleteActions[ref.OnDelete]; action != "" {
+		clause += " ON DELETE " + action
+	}
+	return clause
+}
+
+// ForeignKeyStatement adds the foreign key of a field added to an existing
+// table, for dialects without inline references.
+func (this *Model) ForeignKeyStatement(field *Field) string {
+	return fmt.Sprintf("ALTER TABLE %s ADD FOREIGN KEY (%s) %s;", this.QuotedTable(), QuoteIdent(field.Column), referenceClause(field.References))
+}
+
+// UniqueGroups collects the columns of each composite unique constraint in
+// declaration order.
+func (this *Model) UniqueGroups() ([]string, map[string][]string) {
+	var names []string
+	groups := map[string][]string{}
+	for _, field := range this.Fields {
+		for _, group := range field.UniqueGroups {
+			if _, ok := groups[group]; !ok {
+				names = append(names, group)
+			}
+			groups[group] = append(groups[group], field.Column)
+		}
+	}
+	return names, groups
+}
+
+// UniqueKeys lists the unique keys declared apart from the columns: the
+// composite groups and, on soft-delete models, the unique fields too.
+func (this *Model) UniqueKeys() ([]string, map[string][]string) {
+	names, keys := this.UniqueGroups()
+	if !this.Options.SoftDelete {
+		return names, keys
+	}
+
+	var fields []string
+	for _, field := range this.Fields {
+		if field.Unique && !field.PrimaryKey {
+			fields = append(fields, field.Column)
+			keys[field.Column] = []string{field.Column}
+		}
+	}
+	return append(fields, names...), keys
+}
+
+// UniqueIndexStatement creates the unique key name of a soft-delete model.
+// It leaves out soft-deleted rows, so a deleted user's email can be used to
+// sign up again.
+func (this *Model) UniqueIndexStatement(d dialect.Dialect, name string) string {
+	index := QuoteIdent(fmt.Sprintf("%s_%s_key", this.Table, name))
+	if !d.SupportsPartialIndex() {
+		return fmt.Sprintf("CREATE UNIQUE INDEX %s ON %s (%s);", index, this.QuotedTable(), this.liveKeyParts(name))
+	}
+
+	_, keys := this.UniqueKeys()
+	columns := make([]string, len(keys[name]))
+	for i, column := range keys[name] {
+		columns[i] = QuoteIdent(column)
+	}
+	ifNotExists := ""
+	if d.SupportsIndexIfNotExists() {
+		ifNotExists = " IF NOT EXISTS"
+	}
+	return fmt.Sprintf(
+		"CREATE UNIQUE INDEX%s %s ON %s (%s) WHERE %s IS NULL;",
+		ifNotExists, index, this.QuotedTable(), strings.Join(columns, ", "), QuoteIdent(DeletedAtColumn),
+	)
+}
+
+// liveKeyParts keys on each column while the row is live and on NULL, which
+// never collides, once it is soft-deleted.
+func (this *Model) liveKeyParts(name string) string {
+	_, keys := this.UniqueKeys()
+	parts := make([]string, len(keys[name]))
+	for i, column := range keys[name] {
+		parts[i] = fmt.Sprintf("(CASE WHEN %s IS NULL THEN %s END)", QuoteIdent(DeletedAtColumn), QuoteIdent(column))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// GenerateCreateTableSQL generates a CREATE TABLE SQL statement for a model
+// in dialect d.
+func GenerateCreateTableSQL(d dialect.Dialect, model *Model) (string, error) {
+	var columns []string
+	var primaryKeys []string
+
+	for _, field := range model.Fields {
+		columns = append(columns, ColumnDefinition(d, model, field))
+
+		if field.PrimaryKey {
+			primaryKeys = append(primaryKeys, QuoteIdent(field.Column))
+		}
+	}
+
+	if len(columns) == 0 {
+		return "", fmt.Errorf("model '%s' has no fields", model.Name)
+	}
+
+	query := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (%s`, model.QuotedTable(), strings.Join(columns, ", "))
+	if len(primaryKeys) > 0 {
+		query += fmt.Sprintf(", PRIMARY KEY (%s)", strings.Join(primaryKeys, ", "))
+	}
+
+	names, groups := model.UniqueKeys()
+	for _, name := range names {
+		if model.Options.SoftDelete {
+			// Partial indexes follow the table, see GenerateIndexSQL.
+			if !d.SupportsPartialIndex() {
+				query += fmt.Sprintf(", UNIQUE INDEX %s (%s)", QuoteIdent(fmt.Sprintf("%s_%s_key", model.Table, name)), model.liveKeyParts(name))
+			}
+			continue
+		}
+		columns := make([]string, len(groups[name]))
+		for i, column := range groups[name] {
+			columns[i] = QuoteIdent(column)
+		}
+		constraint := QuoteIdent(fmt.Sprintf("%s_%s_key", model.Table, name))
+		query += fmt.Sprintf(", CONSTRAINT %s UNIQUE (%s)", constraint, strings.Join(columns, ", "))
+	}
+
+	for _, field := range model.Fields {
+		if field.References != nil && !d.SupportsInlineReferences() {
+			query += fmt.Sprintf(", FOREIGN KEY (%s) %s", QuoteIdent(field.Column), referenceClause(field.References))
+		}
+		// Declared with the table, CREATE TABLE IF NOT EXISTS keeps them
+		// idempotent.
+		if field.Index && !d.SupportsIndexIfNotExists() {
+			query += fmt.Sprintf(", INDEX %s (%s)", QuoteIdent(model.IndexName(field.Column)), QuoteIdent(field.Column))
+		}
+	}
+	query += ");"
+
+	return query, nil
+}
+
+// IndexName is the name GenerateIndexSQL gives the index on column.
+func (this *Model) IndexName(column string) string {
+	return fmt.Sprintf("%s_%s_idx", this.Table, column)
+}
+
+// GenerateIndexSQL generates CREATE INDEX statements for indexed fields in
+// dialect d, and the partial unique indexes of soft-delete models. Dialects
+// without CREATE INDEX IF NOT EXISTS or partial indexes get those from
+// GenerateCreateTableSQL instead.
+func GenerateIndexSQL(d dialect.Dialect, model *Model) []string {
+	var statements []string
+	if d.SupportsIndexIfNotExists() {
+		for _, field := range model.Fields {
+			if field.Index {
+				statements = append(statements, model.IndexStatement(d, field))
+			}
+		}
+	}
+	if model.Options.SoftDelete && d.SupportsPartialIndex() {
+		names, _ := model.UniqueKeys()
+		for _, name := range names {
+			statements = append(statements, model.UniqueIndexStatement(d, name))
+		}
+	}
+	return statements
+}
+
+// IndexStatement creates the index on a single field.
+func (this *Model) IndexStatement(d dialect.Dialect, field *Field) string {
+	ifNotExists := ""
+	if d.SupportsIndexIfNotExists() {
+		ifNotExists = " IF NOT EXISTS"
+	}
+	return fmt.Sprintf(
+		"CREATE INDEX%s %s ON %s (%s);",
+		ifNotExists, QuoteIdent(this.IndexName(field.Column)), this.QuotedTable(), QuoteIdent(field.Column),
+	)
+}
@@ -0,0 +1,295 @@
+package models
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Options tune how the adapter treats a model.
+type Options struct {
+	// Table overrides the physical table name, which defaults to the model name.
+	Table string
+	// SoftDelete keeps deleted rows around with a deletedAt timestamp.
+	SoftDelete bool
+	// MaxAffectedRows caps mass updates and deletes. Zero uses the server
+	// default, a negative value disables the cap.
+	MaxAffectedRows int64
+	// Internal models are created with the schema but hidden from the adapter API.
+	Internal bool
+}
+
+// Reference is a foreign key from a field to a column of another model.
+type Reference struct {
+	Model    string // logical name of the referenced model
+	Column   string
+	OnDelete string // cascade, restrict, set null, set default or no action
+}
+
+// Field describes a single column of a model.
+type Field struct {
+	Name   string // Go struct field, empty for dynamic models
+	Column string
+	// Type is the value type with any pointer or sql.Null wrapper removed;
+	// the wrapper is recorded in Nullable instead.
+	Type       reflect.Type
+	Nullable   bool
+	SQLType    string // explicit column type, overrides the dialect mapping
+	PrimaryKey bool
+	Unique     bool
+	// AutoIncrement numbers new rows, for integer primary keys.
+	AutoIncrement bool
+	Default       string // SQL expression
+	References    *Reference
+	Index         bool
+	// UniqueGroups names the composite unique constraints this field is part of.
+	UniqueGroups []string
+	Description  string // from the doc tag, for generated documentation
+}
+
+// Model is a registered table together with everything the adapter needs to
+// know about it.
+type Model struct {
+	Name    string // logical name used by Better Auth
+	Table   string // physical table name
+	Type    reflect.Type
+	Fields  []*Field
+	Options Options
+}
+
+const DeletedAtColumn = "deletedAt"
+
+var (
+	identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	sqlTypePattern    = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_ ]*(\([0-9, ]+\))?( ?\[\])*$`)
+)
+
+// ValidIdentifier reports whether name is safe to use as a table or column name.
+func ValidIdentifier(name string) bool {
+	return identifierPattern.MatchString(name)
+}
+
+var registry = struct {
+	sync.RWMutex
+	models map[string]*Model
+	order  []string
+}{models: map[string]*Model{}}
+
+// UnwrapNullable strips pointers and database/sql Null wrappers (NullString,
+// NullTime, Null[T], ...) and reports whether t can hold NULL.
+func UnwrapNullable(t reflect.Type) (reflect.Type, bool) {
+	if t.Kind() == reflect.Pointer {
+		return t.Elem(), true
+	}
+	if t.Kind() == reflect.Struct && t.PkgPath() == "database/sql" && strings.HasPrefix(t.Name(), "Null") &&
+		t.NumField() == 2 && t.Field(1).Name == "Valid" {
+		return t.Field(0).Type, true
+	}
+	return t, false
+}
+
+// FromStruct builds a model from a struct's db and pk tags.
+func FromStruct(name string, v interface{}, options Options) (*Model, error) {
+	t := reflect.TypeOf(v)
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("expected a struct")
+	}
+
+	model := &Model{Name: name, Table: options.Table, Type: t, Options: options}
+	if model.Table == "" {
+		model.Table = name
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		structField := t.Field(i)
+		column := structField.Tag.Get("db")
+		if column == "" {
+			continue // Skip fields without a db tag
+		}
+
+		valueType, nullable := UnwrapNullable(structField.Type)
+		field := &Field{
+			Name:          structField.Name,
+			Column:        column,
+			Type:          valueType,
+			Nullable:      nullable && structField.Tag.Get("notnull") != "true",
+			SQLType:       structField.Tag.Get("sqltype"),
+			PrimaryKey:    structField.Tag.Get("pk") == "true",
+			Unique:        structField.Tag.Get("unique") == "true",
+			AutoIncrement: structField.Tag.Get("autoincrement") == "true",
+			Default:       structField.Tag.Get("default"),
+			Index:         structField.Tag.Get("index") == "true",
+			Description:   structField.Tag.Get("doc"),
+		}
+
+		if references := structField.Tag.Get("references"); references != "" {
+			target, targetColumn, ok := strings.Cut(references, ".")
+			if !ok {
+				return nil, fmt.Errorf("references tag on '%s' must look like model.column", column)
+			}
+			field.References = &Reference{
+				Model:    target,
+				Column:   targetColumn,
+				OnDelete: strings.ToLower(structField.Tag.Get("onDelete")),
+			}
+		}
+
+		if groups := structField.Tag.Get("uniqueGroup"); groups != "" {
+			field.UniqueGroups = strings.Split(groups, ",")
+		}
+
+		model.Fields = append(model.Fields, field)
+	}
+
+	if len(model.Fields) == 0 {
+		return nil, fmt.Errorf("no fields with db tags found in struct")
+	}
+
+	return model, nil
+}
+
+// Validate checks every name and type in the model is safe to put in SQL.
+func (this *Model) Validate() error {
+	if !ValidIdentifier(this.Name) {
+		return fmt.Errorf("invalid model name '%s'", this.Name)
+	}
+	if !ValidIdentifier(this.Table) {
+		return fmt.Errorf("invalid table name '%s'", this.Table)
+	}
+
+	seen := map[string]bool{}
+	for _, field := range this.Fields {
+		if !ValidIdentifier(field.Column) {
+			return fmt.Errorf("invalid column name '%s' in model '%s'", field.Column, this.Name)
+		}
+		if seen[field.Column] {
+			return fmt.Errorf("duplicate column '%s' in model '%s'", field.Column, this.Name)
+		}
+		seen[field.Column] = true
+
+		if field.SQLType != "" && !sqlTypePattern.MatchString(field.SQLType) {
+			return fmt.Errorf("invalid sqltype '%s' on '%s' in model '%s'", field.SQLType, field.Column, this.Name)
+		}
+		if field.AutoIncrement && (!field.PrimaryKey || !isInteger(field.Type)) {
+			return fmt.Errorf("autoincrement on '%s' in model '%s' needs an integer primary key", field.Column, this.Name)
+		}
+		if ref := field.References; ref != nil {
+			if !ValidIdentifier(ref.Model) || !ValidIdentifier(ref.Column) {
+				return fmt.Errorf("invalid reference on '%s' in model '%s'", field.Column, this.Name)
+			}
+			if _, ok := onDeleteActions[ref.OnDelete]; !ok {
+				return fmt.Errorf("invalid onDelete '%s' on '%s' in model '%s'", ref.OnDelete, field.Column, this.Name)
+			}
+		}
+		for _, group := range field.UniqueGroups {
+			if !ValidIdentifier(group) {
+				return fmt.Errorf("invalid unique group '%s' in model '%s'", group, this.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+func isInteger(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// Add validates and registers a model, replacing any earlier model with the same name.
+func Add(model *Model) error {
+	if model.Table == "" {
+		model.Table = model.Name
+	}
+	if err := model.Validate(); err != nil {
+		return err
+	}
+
+	if _, ok := model.Field(DeletedAtColumn); model.Options.SoftDelete && !ok {
+		model.Fields = append(model.Fields, &Field{
+			Column:      DeletedAtColumn,
+			Type:        reflect.TypeOf(time.Time{}),
+			Nullable:    true,
+			Description: "When the row was soft-deleted, NULL while it is live",
+		})
+	}
+
+	registry.Lock()
+	defer registry.Unlock()
+
+	if _, exists := registry.models[model.Name]; !exists {
+		registry.order = append(registry.order, model.Name)
+	}
+	registry.models[model.Name] = model
+	return nil
+}
+
+// Register adds a struct-backed model and panics on invalid definitions, for
+// use from init functions.
+func Register(name string, v interface{}, options Options) *Model {
+	model, err := FromStruct(name, v, options)
+	if err == nil {
+		err = Add(model)
+	}
+	if err != nil {
+		panic(fmt.Sprintf("models: cannot register '%s': %s", name, err.Error()))
+	}
+	return model
+}
+
+// Lookup finds a model by its logical name.
+func Lookup(name string) (*Model, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	model, ok := registry.models[name]
+	return model, ok
+}
+
+// All returns the registered models in registration order.
+func All() []*Model {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	all := make([]*Model, 0, len(registry.order))
+	for _, name := range registry.order {
+		all = append(all, registry.models[name])
+	}
+	return all
+}
+
+// Field finds a field by column name.
+func (this *Model) Field(column string) (*Field, bool) {
+	for _, field := range this.Fields {
+		if field.Column == column {
+			return field, true
+		}
+	}
+	return nil, false
+}
+
+// Columns lists the column names in declaration order.
+func (this *Model) Columns() []string {
+	columns := make([]string, len(this.Fields))
+	for i, field := range this.Fields {
+		columns[i] = field.Column
+	}
+	return columns
+}
+
+// QuotedTable is the physical table name quoted for SQL.
+func (this *Model) QuotedTable() string {
+	return QuoteIdent(this.Table)
+}
+
+// QuoteIdent quotes a table or column name for use in SQL.
+func QuoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
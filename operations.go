@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"hack/backend/models"
+	s "hack/backend/server"
+	"hack/backend/storage"
+	u "hack/backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// store is where the adapter endpoints and the admin read and write rows.
+var store storage.Storage = storage.NewSQL(&db)
+
+// storageFailure turns a storage error into a response. Requests the
+// storage refused are the caller's fault, a unique key taken is a 409 and
+// another broken constraint a 422, an outage is a 503, anything else is
+// logged.
+func storageFailure(err error, message string) (int, gin.H) {
+	var invalid *storage.InvalidError
+	if errors.As(err, &invalid) {
+		return http.StatusBadRequest, gin.H{"error": invalid.Error()}
+	}
+	if violation, ok := storage.Violation(err); ok {
+		switch violation.Constraint {
+		case storage.Unique:
+			return http.StatusConflict, gin.H{"error": "Unique constraint violated"}
+		case storage.ForeignKey:
+			return http.StatusUnprocessableEntity, gin.H{"error": "Foreign key constraint violated"}
+		}
+		return http.StatusUnprocessableEntity, gin.H{"error": "NOT NULL constraint violated"}
+	}
+	if errors.Is(err, s.ErrUnavailable) || errors.Is(err, storage.ErrNotConnected) {
+		return http.StatusServiceUnavailable, gin.H{"error": "Database unavailable"}
+	}
+	u.ErrorF("%s:\t%s\n", message, err.Error())
+	return http.StatusInternalServerError, gin.H{"error": message}
+}
+
+// parseSortBy reads "field" or "field asc|desc" entries.
+func parseSortBy(sortBy []string) ([]storage.Sort, error) {
+	sorts := make([]storage.Sort, 0, len(sortBy))
+	for _, entry := range sortBy {
+		parts := strings.Fields(entry)
+		if len(parts) == 0 || len(parts) > 2 {
+			return nil, fmt.Errorf("invalid sortBy '%s'", entry)
+		}
+		sort := storage.Sort{Field: parts[0]}
+		if len(parts) == 2 {
+			switch strings.ToLower(parts[1]) {
+			case "asc":
+			case "desc":
+				sort.Descending = true
+			default:
+				return nil, fmt.Errorf("invalid sort direction '%s'", parts[1])
+			}
+		}
+		sorts = append(sorts, sort)
+	}
+	return sorts, nil
+}
+
+// checkSelect validates the fields a request asks to get back.
+func checkSelect(model *models.Model, fields []string) error {
+	for _, field := range fields {
+		if _, ok := model.Field(field); !ok {
+			return fmt.Errorf("unknown field '%s' on model '%s'", field, model.Name)
+		}
+	}
+	return nil
+}
+
+// selectFields keeps the selected fields of row, or all of them when none
+// are selected.
+func selectFields(fields []string, row storage.Row) storage.Row {
+	if len(fields) == 0 {
+		return row
+	}
+	selected := make(storage.Row, len(fields))
+	for _, field := range fields {
+		selected[field] = row[field]
+	}
+	return selected
+}
+
+func runCreate(ctx context.Context, tx storage.Storage, model *models.Model, data interface{}, fields []string) (int, gin.H) {
+	row, err := coerceData(model, data)
+	if err != nil {
+		return http.StatusBadRequest, gin.H{"error": err.Error()}
+	}
+	if err := checkSelect(model, fields); err != nil {
+		return http.StatusBadRequest, gin.H{"error": err.Error()}
+	}
+
+	created, err := tx.Create(ctx, model, row)
+	if err != nil {
+		return storageFailure(err, "Query Execution Failed")
+	}
+	return http.StatusOK, gin.H(selectFields(fields, created))
+}
+
+func runUpdate(ctx context.Context, tx storage.Storage, model *models.Model, where []Where, update interface{}, ifMatch *IfMatch) (int, gin.H) {
+	if len(where) == 0 {
+		return http.StatusBadRequest, gin.H{"error": "A 'where' clause is required for update"}
+	}
+
+	row, err := coerceData(model, update)
+	if err != nil {
+		return http.StatusBadRequest, gin.H{"error": err.Error()}
+	}
+
+	_, err = tx.Update(ctx, model, where, row, ifMatch)
+	var conflict *storage.ConflictError
+	switch {
+	case errors.As(err, &conflict):
+		return http.StatusConflict, gin.H{"error": "Precondition Failed", "current": conflict.Current}
+	case errors.Is(err, storage.ErrNotFound):
+		return http.StatusNotFound, gin.H{"error": "Not Found"}
+	case err != nil:
+		return storageFailure(err, "Query Execution Failed")
+	}
+	return http.StatusOK, gin.H{"message": "success"}
+}
+
+// massResult reports the outcome of a guarded update-many or delete-many.
+func massResult(model *models.Model, affected int64, dryRun bool, err error) (int, gin.H) {
+	var limit *storage.LimitError
+	switch {
+	case errors.As(err, &limit):
+		u.WarnF("Rolled back mass mutation on %s: %s\n", model.Name, limit.Error())
+		return http.StatusUnprocessableEntity, gin.H{"error": "Too many rows affected", "count": limit.Count, "limit": limit.Limit}
+	case err != nil:
+		return storageFailure(err, "Query Execution Failed")
+	case dryRun:
+		return http.StatusOK, gin.H{"count": affected, "dryRun": true}
+	}
+	return http.StatusOK, gin.H{"message": "success", "count": affected}
+}
+
+func runUpdateMany(ctx context.Context, tx storage.Storage, model *models.Model, where []Where, update interface{}, allowAll bool, dryRun bool) (int, gin.H) {
+	if len(where) == 0 && !allowAll {
+		return http.StatusBadRequest, gin.H{"error": "Refusing to update every row without 'allowAll'"}
+	}
+
+	row, err := coerceData(model, update)
+	if err != nil {
+		return http.StatusBadRequest, gin.H{"error": err.Error()}
+	}
+
+	affected, err := tx.UpdateMany(ctx, model, where, row, storage.Mutation{Limit: maxAffectedRows(model), DryRun: dryRun})
+	return massResult(model, affected, dryRun, err)
+}
+
+func runDelete(ctx context.Context, tx storage.Storage, model *models.Model, where []Where) (int, gin.H) {
+	if len(where) == 0 {
+		return http.StatusBadRequest, gin.H{"error": "A 'where' clause is required for delete"}
+	}
+
+	if err := tx.Delete(ctx, model, where); err != nil {
+		return storageFailure(err, "Query Execution Failed")
+	}
+	return http.StatusOK, gin.H{"message": "success"}
+}
+
+func runDeleteMany(ctx context.Context, tx storage.Storage, model *models.Model, where []Where, allowAll bool, dryRun bool) (int, gin.H) {
+	if len(where) == 0 && !allowAll {
+		return http.StatusBadRequest, gin.H{"error": "Refusing to delete every row without 'allowAll'"}
+	}
+
+	affected, err := tx.DeleteMany(ctx, model, where, storage.Mutation{Limit: maxAffectedRows(model), DryRun: dryRun})
+	return massResult(model, affected, dryRun, err)
+}
+
+// runOperation runs one step of a /transaction request.
+func runOperation(ctx context.Context, tx storage.Storage, operation TransactionOperation) (int, gin.H) {
+	model, err := lookupModel(operation.Model)
+	if err != nil {
+		return http.StatusBadRequest, gin.H{"error": err.Error()}
+	}
+
+	switch operation.Action {
+	case "create":
+		return runCreate(ctx, tx, model, operation.Data, operation.Select)
+	case "update":
+		return runUpdate(ctx, tx, model, operation.Where, operation.Update, operation.IfMatch)
+	case "update-many":
+		return runUpdateMany(ctx, tx, model, operation.Where, operation.Update, operation.AllowAll, operation.DryRun)
+	case "delete":
+		return runDelete(ctx, tx, model, operation.Where)
+	case "delete-many":
+		return runDeleteMany(ctx, tx, model, operation.Where, operation.AllowAll, operation.DryRun)
+	}
+	return http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown action '%s'", operation.Action)}
+}
+
+// errOperationFailed rolls a /transaction back after a failed operation,
+// whose response is reported instead.
+var errOperationFailed = errors.New("operation failed")
+
+// runTransaction applies every operation or none of them.
+func runTransaction(ctx context.Context, operations []TransactionOperation) (int, gin.H) {
+	results := make([]gin.H, 0, len(operations))
+	var status int
+	var failure gin.H
+
+	err := store.Transaction(ctx, func(tx storage.Storage) error {
+		for i, operation := range operations {
+			code, payload := runOperation(ctx, tx, operation)
+			if code >= http.StatusBadRequest {
+				status, failure = code, gin.H{"operation": i}
+				for key, value := range payload {
+					failure[key] = value
+				}
+				return errOperationFailed
+			}
+			results = append(results, payload)
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, errOperationFailed):
+		return status, failure
+	case err != nil:
+		return storageFailure(err, "Transaction Failed")
+	}
+	return http.StatusOK, gin.H{"results": results}
+}
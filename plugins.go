@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"hack/backend/models"
+)
+
+// TwoFactor represents the twoFactor table of the two-factor plugin
+type TwoFactor struct {
+	ID          string `db:"id" pk:"true"`
+	Secret      string `db:"secret"`
+	BackupCodes string `db:"backupCodes"`
+	UserID      string `db:"userId" references:"user.id" onDelete:"cascade" index:"true"`
+}
+
+// TwoFactorUser holds the columns the two-factor plugin adds to user
+type TwoFactorUser struct {
+	TwoFactorEnabled *bool `db:"twoFactorEnabled" default:"false"`
+}
+
+// Passkey represents the passkey table of the passkey plugin
+type Passkey struct {
+	ID           string     `db:"id" pk:"true"`
+	Name         *string    `db:"name"`
+	PublicKey    string     `db:"publicKey"`
+	UserID       string     `db:"userId" references:"user.id" onDelete:"cascade" index:"true"`
+	CredentialID string     `db:"credentialID" index:"true"`
+	Counter      int        `db:"counter"`
+	DeviceType   string     `db:"deviceType"`
+	BackedUp     bool       `db:"backedUp"`
+	Transports   *string    `db:"transports"`
+	CreatedAt    *time.Time `db:"createdAt" default:"CURRENT_TIMESTAMP"`
+	AAGUID       *string    `db:"aaguid"`
+}
+
+// Organization represents the organization table of the organization plugin
+type Organization struct {
+	ID        string    `db:"id" pk:"true"`
+	Name      string    `db:"name"`
+	Slug      string    `db:"slug" unique:"true"`
+	Logo      *string   `db:"logo"`
+	Metadata  *string   `db:"metadata"`
+	CreatedAt time.Time `db:"createdAt" default:"CURRENT_TIMESTAMP"`
+}
+
+// Member represents the member table of the organization plugin
+type Member struct {
+	ID             string    `db:"id" pk:"true"`
+	OrganizationID string    `db:"organizationId" references:"organization.id" onDelete:"cascade" index:"true"`
+	UserID         string    `db:"userId" references:"user.id" onDelete:"cascade" index:"true"`
+	Role           string    `db:"role" default:"'member'"`
+	CreatedAt      time.Time `db:"createdAt" default:"CURRENT_TIMESTAMP"`
+}
+
+// Invitation represents the invitation table of the organization plugin
+type Invitation struct {
+	ID             string    `db:"id" pk:"true"`
+	OrganizationID string    `db:"organizationId" references:"organization.id" onDelete:"cascade" index:"true"`
+	Email          string    `db:"email" index:"true"`
+	Role           *string   `db:"role"`
+	Status         string    `db:"status" default:"'pending'"`
+	ExpiresAt      time.Time `db:"expiresAt"`
+	InviterID      string    `db:"inviterId" references:"user.id" onDelete:"cascade"`
+	CreatedAt      time.Time `db:"createdAt" default:"CURRENT_TIMESTAMP"`
+}
+
+// OrganizationSession holds the columns the organization plugin adds to session
+type OrganizationSession struct {
+	ActiveOrganizationID *string `db:"activeOrganizationId"`
+}
+
+// APIKey represents the apikey table of the API key plugin
+type APIKey struct {
+	ID                  string     `db:"id" pk:"true"`
+	Name                *string    `db:"name"`
+	Start               *string    `db:"start"`
+	Prefix              *string    `db:"prefix"`
+	Key                 string     `db:"key" index:"true"`
+	UserID              string     `db:"userId" references:"user.id" onDelete:"cascade" index:"true"`
+	RefillInterval      *int       `db:"refillInterval"`
+	RefillAmount        *int       `db:"refillAmount"`
+	LastRefillAt        *time.Time `db:"lastRefillAt"`
+	Enabled             bool       `db:"enabled" default:"true"`
+	RateLimitEnabled    bool       `db:"rateLimitEnabled" default:"true"`
+	RateLimitTimeWindow *int       `db:"rateLimitTimeWindow"`
+	RateLimitMax        *int       `db:"rateLimitMax"`
+	RequestCount        int        `db:"requestCount" default:"0"`
+	Remaining           *int       `db:"remaining"`
+	LastRequest         *time.Time `db:"lastRequest"`
+	ExpiresAt           *time.Time `db:"expiresAt"`
+	CreatedAt           time.Time  `db:"createdAt" default:"CURRENT_TIMESTAMP"`
+	UpdatedAt           time.Time  `db:"updatedAt" default:"CURRENT_TIMESTAMP"`
+	Permissions         *string    `db:"permissions"`
+	Metadata            *string    `db:"metadata"`
+}
+
+// JWKS represents the jwks table of the JWT plugin
+type JWKS struct {
+	ID         string    `db:"id" pk:"true"`
+	PublicKey  string    `db:"publicKey"`
+	PrivateKey string    `db:"privateKey"`
+	CreatedAt  time.Time `db:"createdAt" default:"CURRENT_TIMESTAMP"`
+}
+
+// AdminUser holds the columns the admin plugin adds to user
+type AdminUser struct {
+	Role       *string    `db:"role"`
+	Banned     *bool      `db:"banned" default:"false"`
+	BanReason  *string    `db:"banReason"`
+	BanExpires *time.Time `db:"banExpires"`
+}
+
+// AdminSession holds the columns the admin plugin adds to session
+type AdminSession struct {
+	ImpersonatedBy *string `db:"impersonatedBy"`
+}
+
+type pluginModel struct {
+	Name    string
+	Struct  interface{}
+	Options models.Options
+}
+
+// Plugin lists the tables a Better Auth plugin needs and the columns it adds
+// to existing ones.
+type Plugin struct {
+	Models  []pluginModel
+	Extends []pluginModel
+}
+
+// Plugins are keyed by the Better Auth plugin id.
+var Plugins = map[string]Plugin{
+	"two-factor": {
+		Models:  []pluginModel{{Name: "twoFactor", Struct: TwoFactor{}}},
+		Extends: []pluginModel{{Name: "user", Struct: TwoFactorUser{}}},
+	},
+	"passkey": {
+		Models: []pluginModel{{Name: "passkey", Struct: Passkey{}}},
+	},
+	"organization": {
+		Models: []pluginModel{
+			{Name: "organization", Struct: Organization{}},
+			{Name: "member", Struct: Member{}},
+			{Name: "invitation", Struct: Invitation{}},
+		},
+		Extends: []pluginModel{{Name: "session", Struct: OrganizationSession{}}},
+	},
+	"api-key": {
+		Models: []pluginModel{{Name: "apikey", Struct: APIKey{}}},
+	},
+	"jwt": {
+		Models: []pluginModel{{Name: "jwks", Struct: JWKS{}}},
+	},
+	"admin": {
+		Extends: []pluginModel{
+			{Name: "user", Struct: AdminUser{}},
+			{Name: "session", Struct: AdminSession{}},
+		},
+	},
+}
+
+// EnabledPlugins is the list of plugin ids enablePlugins registered.
+var EnabledPlugins []string
+
+// enablePlugins registers the models of the given plugins, in the order the
+// ids are given.
+func enablePlugins(ids []string) error {
+	for _, id := range ids {
+		id = strings.TrimSpace(id)
+		if id == "" || pluginEnabled(id) {
+			continue
+		}
+		plugin, ok := Plugins[id]
+		if !ok {
+			return fmt.Errorf("unknown plugin '%s', expected one of %s", id, strings.Join(pluginIDs(), ", "))
+		}
+
+		for _, definition := range plugin.Models {
+			model, err := models.FromStruct(definition.Name, definition.Struct, definition.Options)
+			if err != nil {
+				return fmt.Errorf("plugin '%s': %w", id, err)
+			}
+			if err := models.Add(model); err != nil {
+				return fmt.Errorf("plugin '%s': %w", id, err)
+			}
+		}
+
+		for _, definition := range plugin.Extends {
+			existing, ok := models.Lookup(definition.Name)
+			if !ok {
+				return fmt.Errorf("plugin '%s' extends unknown model '%s'", id, definition.Name)
+			}
+			fields, err := models.FromStruct(definition.Name, definition.Struct, models.Options{})
+			if err != nil {
+				return fmt.Errorf("plugin '%s': %w", id, err)
+			}
+			extended, _ := existing.Extend(fields)
+			if err := models.Add(extended); err != nil {
+				return fmt.Errorf("plugin '%s': %w", id, err)
+			}
+		}
+
+		EnabledPlugins = append(EnabledPlugins, id)
+	}
+	return nil
+}
+
+func pluginIDs() []string {
+	ids := make([]string, 0, len(Plugins))
+	for id := range Plugins {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func pluginEnabled(id string) bool {
+	for _, enabled := range EnabledPlugins {
+		if enabled == id {
+			return true
+		}
+	}
+	return false
+}
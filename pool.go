@@ -0,0 +1,52 @@
+package main
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// poolReport describes the connection pool and the read replicas for the
+// admin pages, to size the pool against the database's connection limit.
+func poolReport() gin.H {
+	pool := db.Config().Pool
+	stats, connected := db.Stats()
+
+	replicas := []gin.H{}
+	for _, replica := range db.Replicas() {
+		replicas = append(replicas, gin.H{
+			"host":            replica.Host,
+			"healthy":         replica.Healthy,
+			"checkedAt":       replica.CheckedAt,
+			"lastError":       replica.LastError,
+			"breaker":         replica.Breaker,
+			"openConnections": replica.Stats.OpenConnections,
+			"inUse":           replica.Stats.InUse,
+			"idle":            replica.Stats.Idle,
+			"waitCount":       replica.Stats.WaitCount,
+		})
+	}
+
+	return gin.H{
+		"connected": connected,
+		"config": gin.H{
+			"maxOpenConns":    pool.MaxOpenConns,
+			"maxIdleConns":    pool.MaxIdleConns,
+			"connMaxLifetime": pool.ConnMaxLifetime.String(),
+			"connMaxIdleTime": pool.ConnMaxIdleTime.String(),
+		},
+		"stats": gin.H{
+			"maxOpenConnections": stats.MaxOpenConnections,
+			"openConnections":    stats.OpenConnections,
+			"inUse":              stats.InUse,
+			"idle":               stats.Idle,
+			"waitCount":          stats.WaitCount,
+			"waitDuration":       stats.WaitDuration.Round(time.Microsecond).String(),
+			"waitDurationMs":     stats.WaitDuration.Milliseconds(),
+			"maxIdleClosed":      stats.MaxIdleClosed,
+			"maxIdleTimeClosed":  stats.MaxIdleTimeClosed,
+			"maxLifetimeClosed":  stats.MaxLifetimeClosed,
+		},
+		"replicas": replicas,
+	}
+}
@@ -0,0 +1,102 @@
+package schema
+
+import (
+	"fmt"
+
+	"hack/backend/dialect"
+	"hack/backend/models"
+)
+
+type ChangeKind string
+
+const (
+	CreateTable ChangeKind = "create_table"
+	AddColumn   ChangeKind = "add_column"
+	AlterType   ChangeKind = "alter_type"
+	CreateIndex ChangeKind = "create_index"
+)
+
+// Change is one statement needed to bring the live database in line with
+// the registered models.
+type Change struct {
+	Kind   ChangeKind `json:"kind"`
+	Model  string     `json:"model"`
+	Column string     `json:"column,omitempty"`
+	From   string     `json:"from,omitempty"`
+	To     string     `json:"to,omitempty"`
+	SQL    string     `json:"sql"`
+}
+
+// Diff compares the registered models with the live tables and returns the
+// statements that add what is missing. Nothing is ever dropped: columns and
+// indexes that exist only in the database are left for a hand-written
+// migration.
+func Diff(d dialect.Dialect, registered []*models.Model, live map[string]*Table) ([]Change, error) {
+	var changes []Change
+
+	for _, model := range registered {
+		table, ok := live[model.Table]
+		if !ok {
+			statement, err := models.GenerateCreateTableSQL(d, model)
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, Change{Kind: CreateTable, Model: model.Name, SQL: statement})
+			for _, statement := range models.GenerateIndexSQL(d, model) {
+				changes = append(changes, Change{Kind: CreateIndex, Model: model.Name, SQL: statement})
+			}
+			continue
+		}
+
+		for _, field := range model.Fields {
+			column, ok := table.Column(field.Column)
+			if !ok {
+				changes = append(changes, Change{
+					Kind:   AddColumn,
+					Model:  model.Name,
+					Column: field.Column,
+					SQL:    fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s;", model.QuotedTable(), models.ColumnDefinition(d, model, field)),
+				})
+				if field.Unique && model.Options.SoftDelete {
+					changes = append(changes, Change{
+						Kind:   CreateIndex,
+						Model:  model.Name,
+						Column: field.Column,
+						SQL:    model.UniqueIndexStatement(d, field.Column),
+					})
+				}
+				continue
+			}
+
+			want := field.ColumnType(d)
+			if CanonicalType(want) != column.Type {
+				quoted := models.QuoteIdent(field.Column)
+				changes = append(changes, Change{
+					Kind:   AlterType,
+					Model:  model.Name,
+					Column: field.Column,
+					From:   column.Type,
+					To:     CanonicalType(want),
+					SQL:    fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s USING %s::%s;", model.QuotedTable(), quoted, want, quoted, want),
+				})
+			}
+		}
+
+		for _, field := range model.Fields {
+			if !field.Index {
+				continue
+			}
+			if _, ok := table.Indexes[model.IndexName(field.Column)]; ok {
+				continue
+			}
+			changes = append(changes, Change{
+				Kind:   CreateIndex,
+				Model:  model.Name,
+				Column: field.Column,
+				SQL:    model.IndexStatement(d, field),
+			})
+		}
+	}
+
+	return changes, nil
+}
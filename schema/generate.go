@@ -0,0 +1,277 @@
+package schema
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"hack/backend/models"
+)
+
+// GenerateOptions control the Go source GenerateGo writes.
+type GenerateOptions struct {
+	Package string
+	// Register adds an init function registering every struct as a model.
+	Register bool
+}
+
+// goTypes maps canonical Postgres types to the Go types the dialect maps
+// back to the same column type.
+var goTypes = map[string]string{
+	"text":                     "string",
+	"integer":                  "int",
+	"bigint":                   "int64",
+	"smallint":                 "int16",
+	"boolean":                  "bool",
+	"real":                     "float32",
+	"double precision":         "float64",
+	"timestamp with time zone": "time.Time",
+	"bytea":                    "[]byte",
+	"jsonb":                    "json.RawMessage",
+}
+
+// goTypesWithSQLType keep their column type through an explicit sqltype tag.
+var goTypesWithSQLType = map[string]string{
+	"character varying":           "string",
+	"character":                   "string",
+	"citext":                      "string",
+	"uuid":                        "string",
+	"numeric":                     "string",
+	"timestamp without time zone": "time.Time",
+	"date":                        "time.Time",
+	"json":                        "json.RawMessage",
+}
+
+var simpleIndexPattern = regexp.MustCompile(`^CREATE INDEX \S+ ON \S+ USING btree \(("?[A-Za-z_][A-Za-z0-9_]*"?)\)$`)
+
+// literalDefault is a Postgres literal default with its cast, e.g.
+// 'active'::character varying, '{}'::jsonb or NULL::text.
+var literalDefault = regexp.MustCompile(`^\(?('(?:[^']|'')*'|-?[0-9]+(?:\.[0-9]+)?|NULL)\)?(?:::[a-z][a-z ]*(?:\([0-9, ]+\))?(?:\[\])*)*$`)
+
+// normalizeDefault turns a Postgres column default into a default tag every
+// dialect accepts: literals lose their casts and now() is CURRENT_TIMESTAMP.
+// A sequence is reported as autoincrement instead.
+func normalizeDefault(def string) (string, bool) {
+	switch {
+	case strings.HasPrefix(def, "nextval("):
+		return "", true
+	case strings.EqualFold(def, "now()"):
+		return "CURRENT_TIMESTAMP", false
+	}
+	if match := literalDefault.FindStringSubmatch(def); match != nil {
+		if match[1] == "NULL" {
+			return "", false
+		}
+		return match[1], false
+	}
+	return def, false
+}
+
+var initialisms = map[string]bool{
+	"id": true, "ip": true, "url": true, "uri": true, "api": true, "json": true,
+	"http": true, "uuid": true, "sql": true, "jwt": true, "ssl": true,
+}
+
+// GoName turns a table or column name into an exported Go identifier,
+// following the initialism conventions of schemas.go (userId -> UserID).
+func GoName(name string) string {
+	var words []string
+	var word []rune
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, string(word))
+			word = nil
+		}
+	}
+	runes := []rune(name)
+	for i, r := range runes {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			flush()
+			continue
+		case unicode.IsUpper(r) && len(word) > 0 &&
+			(unicode.IsLower(word[len(word)-1]) || i+1 < len(runes) && unicode.IsLower(runes[i+1])):
+			flush()
+		}
+		word = append(word, r)
+	}
+	flush()
+
+	var result strings.Builder
+	for _, w := range words {
+		lower := strings.ToLower(w)
+		if initialisms[lower] {
+			result.WriteString(strings.ToUpper(lower))
+			continue
+		}
+		result.WriteString(strings.ToUpper(lower[:1]) + w[1:])
+	}
+	if result.Len() == 0 || unicode.IsDigit(rune(result.String()[0])) {
+		return "X" + result.String()
+	}
+	return result.String()
+}
+
+// goType picks the Go type for a live column and the sqltype tag needed to
+// keep its exact column type, if any.
+func goType(column *Column) (string, string) {
+	base, suffix := column.Type, ""
+	for strings.HasSuffix(base, "[]") {
+		base = strings.TrimSuffix(base, "[]")
+		suffix += "[]"
+	}
+	name := base
+	if open := strings.Index(name, "("); open >= 0 {
+		name = name[:open]
+	}
+
+	dims := strings.Repeat("[]", len(suffix)/2)
+	if t, ok := goTypes[base]; ok && (dims == "" || t != "[]byte" && t != "json.RawMessage") {
+		return dims + t, ""
+	}
+	if t, ok := goTypesWithSQLType[name]; ok {
+		return dims + t, column.Type
+	}
+	return "string", column.Type
+}
+
+// GenerateGo writes tables as Go structs in the style of schemas.go, with
+// db, pk and constraint tags.
+func GenerateGo(tables []*Table, options GenerateOptions) ([]byte, error) {
+	sorted := append([]*Table{}, tables...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	var body bytes.Buffer
+	usesTime, usesJSON := false, false
+
+	structs := map[string]string{}
+	for _, table := range sorted {
+		if !models.ValidIdentifier(table.Name) {
+			return nil, fmt.Errorf("table '%s' cannot be registered as a model", table.Name)
+		}
+		if other, ok := structs[GoName(table.Name)]; ok {
+			return nil, fmt.Errorf("tables '%s' and '%s' would both be struct %s", other, table.Name, GoName(table.Name))
+		}
+		structs[GoName(table.Name)] = table.Name
+
+		primary := map[string]bool{}
+		unique := map[string]bool{}
+		groups := map[string][]string{}
+		for _, constraint := range table.Constraints {
+			switch {
+			case constraint.Kind == "primary":
+				for _, column := range constraint.Columns {
+					primary[column] = true
+				}
+			case constraint.Kind == "unique" && len(constraint.Columns) == 1:
+				unique[constraint.Columns[0]] = true
+			case constraint.Kind == "unique":
+				group := strings.TrimSuffix(strings.TrimPrefix(constraint.Name, table.Name+"_"), "_key")
+				if !models.ValidIdentifier(group) {
+					group = "unique"
+				}
+				for _, column := range constraint.Columns {
+					groups[column] = append(groups[column], group)
+				}
+			}
+		}
+
+		indexed := map[string]bool{}
+		for _, definition := range table.Indexes {
+			if match := simpleIndexPattern.FindStringSubmatch(definition); match != nil {
+				indexed[strings.Trim(match[1], `"`)] = true
+			}
+		}
+
+		structName := GoName(table.Name)
+		fmt.Fprintf(&body, "// %s represents the %s table\ntype %s struct {\n", structName, table.Name, structName)
+
+		fields := map[string]string{}
+		for _, column := range table.Columns {
+			if !models.ValidIdentifier(column.Name) {
+				return nil, fmt.Errorf("column '%s' of '%s' cannot be mapped", column.Name, table.Name)
+			}
+			if other, ok := fields[GoName(column.Name)]; ok {
+				return nil, fmt.Errorf("columns '%s' and '%s' of '%s' would both be field %s", other, column.Name, table.Name, GoName(column.Name))
+			}
+			fields[GoName(column.Name)] = column.Name
+
+			fieldType, sqlType := goType(column)
+			if column.Nullable {
+				fieldType = "*" + fieldType
+			}
+			usesTime = usesTime || strings.Contains(fieldType, "time.")
+			usesJSON = usesJSON || strings.Contains(fieldType, "json.")
+
+			tags := []string{fmt.Sprintf(`db:%q`, column.Name)}
+			if primary[column.Name] {
+				tags = append(tags, `pk:"true"`)
+			}
+			if sqlType != "" {
+				tags = append(tags, fmt.Sprintf(`sqltype:%q`, strings.ToUpper(sqlType)))
+			}
+			if unique[column.Name] && !primary[column.Name] {
+				tags = append(tags, `unique:"true"`)
+			}
+			def, autoIncrement := normalizeDefault(column.Default)
+			if autoIncrement && primary[column.Name] && strings.HasPrefix(strings.TrimPrefix(fieldType, "*"), "int") {
+				tags = append(tags, `autoincrement:"true"`)
+			}
+			if def != "" && !strings.ContainsAny(def, "`\"") {
+				tags = append(tags, fmt.Sprintf(`default:%q`, def))
+			}
+			if fk, ok := table.ForeignKey(column.Name); ok && len(fk.RefColumns) == 1 {
+				tags = append(tags, fmt.Sprintf(`references:"%s.%s"`, fk.RefTable, fk.RefColumns[0]))
+				if fk.OnDelete != "" && fk.OnDelete != "no action" {
+					tags = append(tags, fmt.Sprintf(`onDelete:%q`, fk.OnDelete))
+				}
+			}
+			if indexed[column.Name] && !primary[column.Name] && !unique[column.Name] {
+				tags = append(tags, `index:"true"`)
+			}
+			if len(groups[column.Name]) > 0 {
+				tags = append(tags, fmt.Sprintf(`uniqueGroup:%q`, strings.Join(groups[column.Name], ",")))
+			}
+
+			fmt.Fprintf(&body, "\t%s %s `%s`\n", GoName(column.Name), fieldType, strings.Join(tags, " "))
+		}
+		body.WriteString("}\n\n")
+	}
+
+	if options.Register {
+		body.WriteString("func init() {\n")
+		for _, table := range sorted {
+			fmt.Fprintf(&body, "\tmodels.Register(%s, %s{}, models.Options{})\n", strconv.Quote(table.Name), GoName(table.Name))
+		}
+		body.WriteString("}\n")
+	}
+
+	var source bytes.Buffer
+	packageName := options.Package
+	if packageName == "" {
+		packageName = "main"
+	}
+	fmt.Fprintf(&source, "// Code generated by generate-models from the live database.\n\npackage %s\n\n", packageName)
+
+	var imports []string
+	if usesJSON {
+		imports = append(imports, `"encoding/json"`)
+	}
+	if usesTime {
+		imports = append(imports, `"time"`)
+	}
+	if options.Register {
+		imports = append(imports, "", `"hack/backend/models"`)
+	}
+	if len(imports) > 0 {
+		fmt.Fprintf(&source, "import (\n%s\n)\n\n", strings.Join(imports, "\n"))
+	}
+	source.Write(body.Bytes())
+
+	return format.Source(source.Bytes())
+}
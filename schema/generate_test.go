@@ -0,0 +1,84 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeDefault(t *testing.T) {
+	tests := []struct {
+		live          string
+		def           string
+		autoIncrement bool
+	}{
+		{"", "", false},
+		{"'x'::text", "'x'", false},
+		{"'active'::character varying", "'active'", false},
+		{"'it''s'::text", "'it''s'", false},
+		{"'{}'::jsonb", "'{}'", false},
+		{"'{}'::text[]", "'{}'", false},
+		{"'0.00'::numeric(10, 2)", "'0.00'", false},
+		{"NULL::character varying", "", false},
+		{"(-1)", "-1", false},
+		{"0", "0", false},
+		{"false", "false", false},
+		{"now()", "CURRENT_TIMESTAMP", false},
+		{"CURRENT_TIMESTAMP", "CURRENT_TIMESTAMP", false},
+		{"nextval('orders_id_seq'::regclass)", "", true},
+		{"gen_random_uuid()", "gen_random_uuid()", false},
+	}
+
+	for _, test := range tests {
+		def, autoIncrement := normalizeDefault(test.live)
+		if def != test.def || autoIncrement != test.autoIncrement {
+			t.Errorf("normalizeDefault(%q) = %q, %v, expected %q, %v", test.live, def, autoIncrement, test.def, test.autoIncrement)
+		}
+	}
+}
+
+func TestGenerateGo(t *testing.T) {
+	tables := []*Table{{
+		Name: "orders",
+		Columns: []*Column{
+			{Name: "id", Type: "integer", Default: "nextval('orders_id_seq'::regclass)"},
+			{Name: "status", Type: "text", Default: "'new'::text"},
+			{Name: "placedAt", Type: "timestamp with time zone", Default: "now()"},
+		},
+		Constraints: []*Constraint{{Name: "orders_pkey", Kind: "primary", Columns: []string{"id"}}},
+	}}
+
+	source, err := GenerateGo(tables, GenerateOptions{})
+	if err != nil {
+		t.Fatalf("failed to generate: %s", err)
+	}
+	for _, expected := range []string{
+		"ID int `db:\"id\" pk:\"true\" autoincrement:\"true\"`",
+		"Status string `db:\"status\" default:\"'new'\"`",
+		"PlacedAt time.Time `db:\"placedAt\" default:\"CURRENT_TIMESTAMP\"`",
+	} {
+		if !strings.Contains(strings.Join(strings.Fields(string(source)), " "), expected) {
+			t.Errorf("expected %s in\n%s", expected, source)
+		}
+	}
+	if strings.Contains(string(source), "nextval") || strings.Contains(string(source), "::") {
+		t.Errorf("expected Postgres defaults to be normalized in\n%s", source)
+	}
+}
+
+func TestGenerateGoNameCollisions(t *testing.T) {
+	columns := []*Table{{Name: "users", Columns: []*Column{
+		{Name: "user_id", Type: "text"},
+		{Name: "userId", Type: "text"},
+	}}}
+	if _, err := GenerateGo(columns, GenerateOptions{}); err == nil || !strings.Contains(err.Error(), "UserID") {
+		t.Errorf("expected columns mapping to the same field to be refused, got %v", err)
+	}
+
+	tables := []*Table{
+		{Name: "api_key", Columns: []*Column{{Name: "id", Type: "text"}}},
+		{Name: "apiKey", Columns: []*Column{{Name: "id", Type: "text"}}},
+	}
+	if _, err := GenerateGo(tables, GenerateOptions{}); err == nil || !strings.Contains(err.Error(), "APIKey") {
+		t.Errorf("expected tables mapping to the same struct to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,238 @@
+package schema
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"hack/backend/dialect"
+	s "hack/backend/server"
+)
+
+// ErrUnsupported is returned by Inspect for dialects it cannot read.
+var ErrUnsupported = errors.New("schema introspection is only supported on postgres")
+
+// Queryer is satisfied by *server.Database and *server.Tx.
+type Queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*s.Rows, error)
+}
+
+// Column is a column as the live database reports it.
+type Column struct {
+	Name     string
+	Type     string // canonical type, see CanonicalType
+	Nullable bool
+	Default  string
+}
+
+// Constraint is a primary key, unique or foreign key constraint.
+type Constraint struct {
+	Name       string
+	Kind       string // primary, unique or foreign
+	Columns    []string
+	RefTable   string
+	RefColumns []string
+	OnDelete   string // cascade, restrict, set null, set default or no action
+}
+
+// Table is a table as the live database reports it.
+type Table struct {
+	Name        string
+	Columns     []*Column
+	Indexes     map[string]string // index name -> definition
+	Constraints []*Constraint
+}
+
+// Column finds a live column by name.
+func (this *Table) Column(name string) (*Column, bool) {
+	for _, column := range this.Columns {
+		if column.Name == name {
+			return column, true
+		}
+	}
+	return nil, false
+}
+
+// Inspect reads the tables, columns and indexes of the current Postgres schema.
+func Inspect(ctx context.Context, d dialect.Dialect, db Queryer) (map[string]*Table, error) {
+	if d.Name() != "postgres" {
+		return nil, ErrUnsupported
+	}
+
+	tables := map[string]*Table{}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT table_name, column_name, data_type, udt_name, character_maximum_length, numeric_precision, numeric_scale, is_nullable, COALESCE(column_default, '')
+		FROM information_schema.columns
+		WHERE table_schema = current_schema()
+		ORDER BY table_name, ordinal_position`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read columns: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tableName, columnName, dataType, udtName, isNullable, columnDefault string
+		var maxLength, precision, scale sql.NullInt64
+		if err := rows.Scan(&tableName, &columnName, &dataType, &udtName, &maxLength, &precision, &scale, &isNullable, &columnDefault); err != nil {
+			return nil, fmt.Errorf("failed to scan column: %w", err)
+		}
+
+		table, ok := tables[tableName]
+		if !ok {
+			table = &Table{Name: tableName, Indexes: map[string]string{}}
+			tables[tableName] = table
+		}
+
+		liveType := dataType
+		switch {
+		case dataType == "ARRAY":
+			liveType = strings.TrimPrefix(udtName, "_") + "[]"
+		case dataType == "USER-DEFINED":
+			liveType = udtName
+		case maxLength.Valid:
+			liveType = fmt.Sprintf("%s(%d)", dataType, maxLength.Int64)
+		case dataType == "numeric" && precision.Valid:
+			liveType = fmt.Sprintf("numeric(%d,%d)", precision.Int64, scale.Int64)
+		}
+
+		table.Columns = append(table.Columns, &Column{
+			Name:     columnName,
+			Type:     CanonicalType(liveType),
+			Nullable: isNullable == "YES",
+			Default:  columnDefault,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	indexRows, err := db.QueryContext(ctx, `SELECT tablename, indexname, indexdef FROM pg_indexes WHERE schemaname = current_schema()`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read indexes: %w", err)
+	}
+	defer indexRows.Close()
+
+	for indexRows.Next() {
+		var tableName, indexName, definition string
+		if err := indexRows.Scan(&tableName, &indexName, &definition); err != nil {
+			return nil, fmt.Errorf("failed to scan index: %w", err)
+		}
+		if table, ok := tables[tableName]; ok {
+			table.Indexes[indexName] = definition
+		}
+	}
+	if err := indexRows.Err(); err != nil {
+		return nil, err
+	}
+
+	constraintRows, err := db.QueryContext(ctx, `
+		SELECT cl.relname, con.conname, con.contype::text,
+			array_to_string(ARRAY(
+				SELECT att.attname FROM unnest(con.conkey) WITH ORDINALITY AS k(num, ord)
+				JOIN pg_attribute att ON att.attrelid = con.conrelid AND att.attnum = k.num ORDER BY k.ord), ','),
+			COALESCE(ref.relname, ''),
+			array_to_string(ARRAY(
+				SELECT att.attname FROM unnest(con.confkey) WITH ORDINALITY AS k(num, ord)
+				JOIN pg_attribute att ON att.attrelid = con.confrelid AND att.attnum = k.num ORDER BY k.ord), ','),
+			con.confdeltype::text
+		FROM pg_constraint con
+		JOIN pg_class cl ON cl.oid = con.conrelid
+		JOIN pg_namespace ns ON ns.oid = cl.relnamespace
+		LEFT JOIN pg_class ref ON ref.oid = con.confrelid
+		WHERE ns.nspname = current_schema() AND con.contype IN ('p', 'u', 'f')
+		ORDER BY cl.relname, con.conname`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read constraints: %w", err)
+	}
+	defer constraintRows.Close()
+
+	for constraintRows.Next() {
+		var tableName, name, kind, columns, refTable, refColumns, onDelete string
+		if err := constraintRows.Scan(&tableName, &name, &kind, &columns, &refTable, &refColumns, &onDelete); err != nil {
+			return nil, fmt.Errorf("failed to scan constraint: %w", err)
+		}
+		table, ok := tables[tableName]
+		if !ok {
+			continue
+		}
+		constraint := &Constraint{
+			Name:     name,
+			Kind:     constraintKinds[kind],
+			Columns:  strings.Split(columns, ","),
+			RefTable: refTable,
+			OnDelete: deleteActions[onDelete],
+		}
+		if refColumns != "" {
+			constraint.RefColumns = strings.Split(refColumns, ",")
+		}
+		table.Constraints = append(table.Constraints, constraint)
+	}
+	return tables, constraintRows.Err()
+}
+
+var constraintKinds = map[string]string{"p": "primary", "u": "unique", "f": "foreign"}
+
+var deleteActions = map[string]string{
+	"a": "no action",
+	"r": "restrict",
+	"c": "cascade",
+	"n": "set null",
+	"d": "set default",
+}
+
+// ForeignKey finds the single-column foreign key on column.
+func (this *Table) ForeignKey(column string) (*Constraint, bool) {
+	for _, constraint := range this.Constraints {
+		if constraint.Kind == "foreign" && len(constraint.Columns) == 1 && constraint.Columns[0] == column {
+			return constraint, true
+		}
+	}
+	return nil, false
+}
+
+var typeAliases = map[string]string{
+	"int":                         "integer",
+	"int4":                        "integer",
+	"int8":                        "bigint",
+	"int2":                        "smallint",
+	"bool":                        "boolean",
+	"float4":                      "real",
+	"float8":                      "double precision",
+	"timestamptz":                 "timestamp with time zone",
+	"timestamp":                   "timestamp without time zone",
+	"timetz":                      "time with time zone",
+	"time":                        "time without time zone",
+	"varchar":                     "character varying",
+	"char":                        "character",
+	"bpchar":                      "character",
+	"decimal":                     "numeric",
+	"timestamp with time zone":    "timestamp with time zone",
+	"timestamp without time zone": "timestamp without time zone",
+}
+
+// CanonicalType normalizes a Postgres type name so that the spelling used in
+// DDL ("TIMESTAMPTZ", "VARCHAR(255)", "TEXT[]") compares equal to what
+// information_schema reports.
+func CanonicalType(sqlType string) string {
+	t := strings.ToLower(strings.TrimSpace(sqlType))
+
+	suffix := ""
+	for strings.HasSuffix(t, "[]") {
+		suffix += "[]"
+		t = strings.TrimSpace(strings.TrimSuffix(t, "[]"))
+	}
+
+	modifier := ""
+	if open := strings.Index(t, "("); open >= 0 {
+		modifier = strings.ReplaceAll(t[open:], " ", "")
+		t = strings.TrimSpace(t[:open])
+	}
+
+	if alias, ok := typeAliases[t]; ok {
+		t = alias
+	}
+	return t + modifier + suffix
+}
@@ -0,0 +1,174 @@
+package schema
+
+import (
+	"hack/backend/dialect"
+	"hack/backend/models"
+)
+
+// Status is how a registered column, index or relation compares with the
+// live database.
+type Status string
+
+const (
+	StatusOK                  Status = "ok"
+	StatusMissing             Status = "missing"
+	StatusTypeMismatch        Status = "type_mismatch"
+	StatusNullabilityMismatch Status = "nullability_mismatch"
+	StatusUnknown             Status = "unknown" // no live schema to compare with
+)
+
+type FieldReport struct {
+	Column       string `json:"column"`
+	Type         string `json:"type"`
+	Nullable     bool   `json:"nullable"`
+	PrimaryKey   bool   `json:"primaryKey"`
+	Unique       bool   `json:"unique"`
+	Default      string `json:"default,omitempty"`
+	Index        bool   `json:"index"`
+	Status       Status `json:"status"`
+	LiveType     string `json:"liveType,omitempty"`
+	LiveNullable bool   `json:"liveNullable"`
+}
+
+type IndexReport struct {
+	Name   string `json:"name"`
+	Column string `json:"column"`
+	Status Status `json:"status"`
+}
+
+type RelationReport struct {
+	Column    string `json:"column"`
+	Model     string `json:"model"`
+	Table     string `json:"table"`
+	RefColumn string `json:"refColumn"`
+	OnDelete  string `json:"onDelete,omitempty"`
+	Status    Status `json:"status"`
+}
+
+type ExtraColumn struct {
+	Column   string `json:"column"`
+	Type     string `json:"type"`
+	Nullable bool   `json:"nullable"`
+}
+
+// ModelReport is a registered model annotated with its live state.
+type ModelReport struct {
+	Name         string           `json:"name"`
+	Table        string           `json:"table"`
+	SoftDelete   bool             `json:"softDelete"`
+	Internal     bool             `json:"internal"`
+	Status       Status           `json:"status"`
+	Fields       []FieldReport    `json:"fields"`
+	ExtraColumns []ExtraColumn    `json:"extraColumns"`
+	Indexes      []IndexReport    `json:"indexes"`
+	Relations    []RelationReport `json:"relations"`
+}
+
+// Compare annotates the registered models with the live tables. With a nil
+// live schema every status is unknown.
+func Compare(d dialect.Dialect, registered []*models.Model, live map[string]*Table) []ModelReport {
+	reports := make([]ModelReport, 0, len(registered))
+
+	for _, model := range registered {
+		report := ModelReport{
+			Name:         model.Name,
+			Table:        model.Table,
+			SoftDelete:   model.Options.SoftDelete,
+			Internal:     model.Options.Internal,
+			Status:       StatusUnknown,
+			Fields:       []FieldReport{},
+			ExtraColumns: []ExtraColumn{},
+			Indexes:      []IndexReport{},
+			Relations:    []RelationReport{},
+		}
+
+		table, exists := live[model.Table]
+		if live != nil {
+			report.Status = StatusOK
+			if !exists {
+				report.Status = StatusMissing
+			}
+		}
+
+		// status picks the live state of a part: unknown without a live
+		// schema, missing when the table or the part is absent.
+		status := func(present bool) Status {
+			switch {
+			case live == nil:
+				return StatusUnknown
+			case !exists || !present:
+				return StatusMissing
+			}
+			return StatusOK
+		}
+
+		for _, field := range model.Fields {
+			fieldReport := FieldReport{
+				Column:     field.Column,
+				Type:       field.ColumnType(d),
+				Nullable:   field.Nullable,
+				PrimaryKey: field.PrimaryKey,
+				Unique:     field.Unique,
+				Default:    field.Default,
+				Index:      field.Index,
+			}
+
+			var column *Column
+			if exists {
+				column, _ = table.Column(field.Column)
+			}
+			fieldReport.Status = status(column != nil)
+			if column != nil {
+				fieldReport.LiveType = column.Type
+				fieldReport.LiveNullable = column.Nullable
+				switch {
+				case CanonicalType(fieldReport.Type) != column.Type:
+					fieldReport.Status = StatusTypeMismatch
+				case column.Nullable != field.Nullable:
+					fieldReport.Status = StatusNullabilityMismatch
+				}
+			}
+			report.Fields = append(report.Fields, fieldReport)
+
+			if field.Index {
+				name := model.IndexName(field.Column)
+				present := false
+				if exists {
+					_, present = table.Indexes[name]
+				}
+				report.Indexes = append(report.Indexes, IndexReport{Name: name, Column: field.Column, Status: status(present)})
+			}
+
+			if ref := field.References; ref != nil {
+				relation := RelationReport{
+					Column:    field.Column,
+					Model:     ref.Model,
+					Table:     ref.Table(),
+					RefColumn: ref.Column,
+					OnDelete:  ref.OnDelete,
+				}
+				present := false
+				if exists {
+					if constraint, ok := table.ForeignKey(field.Column); ok {
+						present = constraint.RefTable == relation.Table &&
+							len(constraint.RefColumns) == 1 && constraint.RefColumns[0] == ref.Column
+					}
+				}
+				relation.Status = status(present)
+				report.Relations = append(report.Relations, relation)
+			}
+		}
+
+		if exists {
+			for _, column := range table.Columns {
+				if _, ok := model.Field(column.Name); !ok {
+					report.ExtraColumns = append(report.ExtraColumns, ExtraColumn{Column: column.Name, Type: column.Type, Nullable: column.Nullable})
+				}
+			}
+		}
+
+		reports = append(reports, report)
+	}
+
+	return reports
+}
@@ -1,22 +1,26 @@
 package main
 
-import "time"
+import (
+	"encoding/json"
+	"time"
 
-type Where struct {
-	Operator  string `json:"operator"`
-	Connector string `json:"connector"`
-	Field     string `json:"field"`
-	Value     string `json:"value"`
-}
+	"hack/backend/models"
+	"hack/backend/storage"
+)
+
+type Where = storage.Where
 
 type FindOneRequestBody struct {
-	Model string  `json:"model"`
-	Where []Where `json:"where"`
+	Model          string   `json:"model"`
+	Where          []Where  `json:"where"`
+	Select         []string `json:"select"`
+	IncludeDeleted bool     `json:"includeDeleted"`
 }
 
 type CountRequestBody struct {
-	Model string  `json:"model"`
-	Where []Where `json:"where"`
+	Model          string  `json:"model"`
+	Where          []Where `json:"where"`
+	IncludeDeleted bool    `json:"includeDeleted"`
 }
 
 type CreateRequestBody struct {
@@ -31,80 +35,141 @@ type DeleteRequestBody struct {
 }
 
 type DeleteManyRequestBody struct {
-	Model string  `json:"model"`
-	Where []Where `json:"where"`
+	Model    string  `json:"model"`
+	Where    []Where `json:"where"`
+	AllowAll bool    `json:"allowAll"`
+	DryRun   bool    `json:"dryRun"`
 }
 
 type FindManyRequestBody struct {
-	Model  string   `json:"model"`
-	Where  []Where  `json:"where"`
-	Limit  int      `json:"limit"`
-	SortBy []string `json:"sortBy"`
-	Offset int      `json:"offset"`
+	Model          string   `json:"model"`
+	Where          []Where  `json:"where"`
+	Select         []string `json:"select"`
+	Limit          int      `json:"limit"`
+	SortBy         []string `json:"sortBy"`
+	Offset         int      `json:"offset"`
+	IncludeDeleted bool     `json:"includeDeleted"`
 }
 
+type IfMatch = storage.IfMatch
+
 type UpdateRequestBody struct {
-	Model  string      `json:"model"`
-	Where  []Where     `json:"where"`
-	Update interface{} `json:"update"`
+	Model   string      `json:"model"`
+	Where   []Where     `json:"where"`
+	Update  interface{} `json:"update"`
+	IfMatch *IfMatch    `json:"ifMatch"`
 }
 
 type UpdateManyRequestBody struct {
-	Model  string      `json:"model"`
-	Where  []Where     `json:"where"`
-	Update interface{} `json:"update"`
+	Model    string      `json:"model"`
+	Where    []Where     `json:"where"`
+	Update   interface{} `json:"update"`
+	AllowAll bool        `json:"allowAll"`
+	DryRun   bool        `json:"dryRun"`
 }
 
+// TransactionOperation is one step of a /transaction request. Action is
+// create, update, update-many, delete or delete-many; the other fields are
+// those of the matching endpoint.
+type TransactionOperation struct {
+	Action   string      `json:"action"`
+	Model    string      `json:"model"`
+	Where    []Where     `json:"where"`
+	Data     interface{} `json:"data"`
+	Select   []string    `json:"select"`
+	Update   interface{} `json:"update"`
+	IfMatch  *IfMatch    `json:"ifMatch"`
+	AllowAll bool        `json:"allowAll"`
+	DryRun   bool        `json:"dryRun"`
+}
+
+type TransactionRequestBody struct {
+	Operations []TransactionOperation `json:"operations"`
+}
+
+// CreateSchemaRequestBody carries tables in Better Auth's schema format.
+// The DDL is only executed when Apply is set, the models are registered
+// either way.
 type CreateSchemaRequestBody struct {
-	// Define the structure for the create schema request body
+	File   string                            `json:"file"`
+	Tables map[string]models.BetterAuthTable `json:"tables"`
+	Apply  bool                              `json:"apply"`
 }
 
 // User represents the user table
 type User struct {
-	ID            string    `db:"id" pk:"true"`
-	Name          string    `db:"name"`
-	Email         string    `db:"email"`
-	EmailVerified bool      `db:"emailVerified"`
-	Image         string    `db:"image"`
-	CreatedAt     time.Time `db:"createdAt"`
-	UpdatedAt     time.Time `db:"updatedAt"`
+	ID            string    `db:"id" pk:"true" doc:"Unique identifier for each user"`
+	Name          string    `db:"name" doc:"User's chosen display name"`
+	Email         string    `db:"email" unique:"true" doc:"User's email address for communication and login"`
+	EmailVerified bool      `db:"emailVerified" default:"false" doc:"Whether the user's email is verified"`
+	Image         *string   `db:"image" doc:"User's image url"`
+	CreatedAt     time.Time `db:"createdAt" default:"CURRENT_TIMESTAMP" doc:"Timestamp of when the user account was created"`
+	UpdatedAt     time.Time `db:"updatedAt" default:"CURRENT_TIMESTAMP" doc:"Timestamp of the last update to the user's information"`
 }
 
 // Session represents the session table
 type Session struct {
-	ID        string    `db:"id" pk:"true"`
-	UserID    string    `db:"userId"`
-	Token     string    `db:"token"`
-	ExpiresAt time.Time `db:"expiresAt"`
-	IPAddress string    `db:"ipAddress"`
-	UserAgent string    `db:"userAgent"`
-	CreatedAt time.Time `db:"createdAt"`
-	UpdatedAt time.Time `db:"updatedAt"`
+	ID        string    `db:"id" pk:"true" doc:"Unique identifier for each session"`
+	UserID    string    `db:"userId" references:"user.id" onDelete:"cascade" index:"true" doc:"The ID of the user"`
+	Token     string    `db:"token" unique:"true" doc:"The unique session token"`
+	ExpiresAt time.Time `db:"expiresAt" doc:"The time when the session expires"`
+	IPAddress *string   `db:"ipAddress" doc:"The IP address of the device"`
+	UserAgent *string   `db:"userAgent" doc:"The user agent information of the device"`
+	CreatedAt time.Time `db:"createdAt" default:"CURRENT_TIMESTAMP" doc:"Timestamp of when the session was created"`
+	UpdatedAt time.Time `db:"updatedAt" default:"CURRENT_TIMESTAMP" doc:"Timestamp of when the session was updated"`
 }
 
 // Account represents the account table
 type Account struct {
-	ID                   string    `db:"id" pk:"true"`
-	UserID               string    `db:"userId"`
-	AccountID            string    `db:"accountId"`
-	ProviderID           string    `db:"providerId"`
-	AccessToken          string    `db:"accessToken"`
-	RefreshToken         string    `db:"refreshToken"`
-	AccessTokenExpiresAt time.Time `db:"accessTokenExpiresAt"`
-	RefreshTokenExpiresAt time.Time `db:"refreshTokenExpiresAt"`
-	Scope                string    `db:"scope"`
-	IDToken              string    `db:"idToken"`
-	Password             string    `db:"password"`
-	CreatedAt            time.Time `db:"createdAt"`
-	UpdatedAt            time.Time `db:"updatedAt"`
+	ID                    string     `db:"id" pk:"true" doc:"Unique identifier for each account"`
+	UserID                string     `db:"userId" references:"user.id" onDelete:"cascade" index:"true" doc:"The ID of the user"`
+	AccountID             string     `db:"accountId" uniqueGroup:"provider_account" doc:"The ID of the account as provided by the SSO or equal to userId for credential accounts"`
+	ProviderID            string     `db:"providerId" uniqueGroup:"provider_account" doc:"The ID of the provider"`
+	AccessToken           *string    `db:"accessToken" doc:"The access token of the account. Returned by the provider"`
+	RefreshToken          *string    `db:"refreshToken" doc:"The refresh token of the account. Returned by the provider"`
+	AccessTokenExpiresAt  *time.Time `db:"accessTokenExpiresAt" doc:"The time when the access token expires"`
+	RefreshTokenExpiresAt *time.Time `db:"refreshTokenExpiresAt" doc:"The time when the refresh token expires"`
+	Scope                 *string    `db:"scope" doc:"The scope of the account. Returned by the provider"`
+	IDToken               *string    `db:"idToken" doc:"The ID token returned from the provider"`
+	Password              *string    `db:"password" doc:"The password of the account. Mainly used for email and password authentication"`
+	CreatedAt             time.Time  `db:"createdAt" default:"CURRENT_TIMESTAMP" doc:"Timestamp of when the account was created"`
+	UpdatedAt             time.Time  `db:"updatedAt" default:"CURRENT_TIMESTAMP" doc:"Timestamp of when the account was updated"`
 }
 
 // Verification represents the verification table
 type Verification struct {
-	ID         string    `db:"id" pk:"true"`
-	Identifier string    `db:"identifier"`
-	Value      string    `db:"value"`
-	ExpiresAt  time.Time `db:"expiresAt"`
-	CreatedAt  time.Time `db:"createdAt"`
-	UpdatedAt  time.Time `db:"updatedAt"`
+	ID         string    `db:"id" pk:"true" doc:"Unique identifier for each verification"`
+	Identifier string    `db:"identifier" index:"true" doc:"The identifier for the verification request"`
+	Value      string    `db:"value" doc:"The value to be verified"`
+	ExpiresAt  time.Time `db:"expiresAt" doc:"The time when the verification request expires"`
+	CreatedAt  time.Time `db:"createdAt" default:"CURRENT_TIMESTAMP" doc:"Timestamp of when the verification request was created"`
+	UpdatedAt  time.Time `db:"updatedAt" default:"CURRENT_TIMESTAMP" doc:"Timestamp of when the verification request was updated"`
+}
+
+// IdempotencyKey stores the response of a mutating request so retries can be replayed
+type IdempotencyKey struct {
+	Key         string    `db:"key" pk:"true"`
+	Fingerprint string    `db:"fingerprint"`
+	Status      int       `db:"status"`
+	ContentType string    `db:"contentType"`
+	Body        []byte    `db:"body"`
+	CreatedAt   time.Time `db:"createdAt"`
+	ExpiresAt   time.Time `db:"expiresAt" index:"true"`
+}
+
+// DynamicModel stores a table definition applied through /create-schema so
+// it can be registered again on startup
+type DynamicModel struct {
+	Key        string          `db:"key" pk:"true"`
+	Definition json.RawMessage `db:"definition"`
+	UpdatedAt  time.Time       `db:"updatedAt"`
+}
+
+func init() {
+	models.Register("user", User{}, models.Options{SoftDelete: true})
+	models.Register("session", Session{}, models.Options{MaxAffectedRows: 100000})
+	models.Register("account", Account{}, models.Options{SoftDelete: true})
+	models.Register("verification", Verification{}, models.Options{MaxAffectedRows: 100000})
+	models.Register("idempotencykey", IdempotencyKey{}, models.Options{Internal: true})
+	models.Register("dynamicmodel", DynamicModel{}, models.Options{Internal: true})
 }
@@ -0,0 +1,247 @@
+package server
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	u "hack/backend/utils"
+)
+
+var ErrUnavailable = errors.New("database is unavailable, reconnecting")
+
+// Backoff spaces out reconnect attempts: Initial doubles per attempt up to
+// Max, and each delay is picked at random between half and all of it so
+// replicas of the server do not retry in lockstep.
+type Backoff struct {
+	Initial time.Duration
+	Max     time.Duration
+}
+
+var ReconnectBackoff = Backoff{Initial: 500 * time.Millisecond, Max: 30 * time.Second}
+
+// BreakerThreshold is how many connection errors in a row open the breaker
+// on a live pool. A failed Connect opens it at once.
+var BreakerThreshold int32 = 3
+
+// ProbeTimeout bounds each reconnect attempt.
+var ProbeTimeout = 5 * time.Second
+
+// now and sleep are the clock of the breaker, replaced in tests.
+var (
+	now   = time.Now
+	sleep = time.Sleep
+)
+
+func (this Backoff) delay(attempt int) time.Duration {
+	delay := this.Initial
+	for i := 1; i < attempt && delay < this.Max; i++ {
+		delay *= 2
+	}
+	delay = min(delay, this.Max)
+	return delay/2 + rand.N(delay/2+1)
+}
+
+type BreakerState string
+
+const (
+	// BreakerClosed lets calls through, the database is reachable.
+	BreakerClosed BreakerState = "closed"
+	// BreakerOpen fails calls fast while the database is down.
+	BreakerOpen BreakerState = "open"
+	// BreakerHalfOpen is a reconnect attempt in progress.
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+// BreakerStatus is a snapshot of the breaker for the admin dashboard.
+type BreakerStatus struct {
+	State       BreakerState
+	Since       time.Time // when it last opened or closed
+	Attempts    int       // reconnect attempts since it opened
+	NextAttempt time.Time
+	LastError   string
+}
+
+// breaker tracks outages of a Database. While open, one goroutine tries to
+// reconnect with backoff and every other call fails with ErrUnavailable.
+type breaker struct {
+	mu          sync.Mutex
+	state       BreakerState
+	since       time.Time
+	attempts    int
+	nextAttempt time.Time
+	lastError   error
+	generation  int // bumped by reset, stops a running reconnect loop
+
+	failures atomic.Int32 // connection errors in a row on a live pool
+}
+
+func (this *breaker) open() bool {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	return this.state == BreakerOpen || this.state == BreakerHalfOpen
+}
+
+// trip opens the breaker and returns the generation for the reconnect loop,
+// false when it was open already.
+func (this *breaker) trip(err error) (int, bool) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.lastError = err
+	if this.state == BreakerOpen || this.state == BreakerHalfOpen {
+		return 0, false
+	}
+	this.state = BreakerOpen
+	this.since = now()
+	this.attempts = 0
+	return this.generation, true
+}
+
+// reset closes the breaker and stops a reconnect loop.
+func (this *breaker) reset() {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if this.state == BreakerOpen || this.state == BreakerHalfOpen {
+		this.since = now()
+	}
+	this.state = BreakerClosed
+	this.generation++
+	this.failures.Store(0)
+}
+
+func (this *breaker) status() BreakerStatus {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	status := BreakerStatus{State: this.state, Since: this.since, Attempts: this.attempts, NextAttempt: this.nextAttempt}
+	if status.State == "" {
+		status.State = BreakerClosed
+	}
+	if this.lastError != nil {
+		status.LastError = this.lastError.Error()
+	}
+	return status
+}
+
+// isConnectionError tells outages apart from errors in the query itself.
+func isConnectionError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var netErr net.Error
+	return errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &netErr)
+}
+
+// Breaker reports the state of the circuit breaker.
+func (this *Database) Breaker() BreakerStatus {
+	return this.breaker.status()
+}
+
+// Unavailable reports whether calls fail fast, and when to retry.
+func (this *Database) Unavailable() (time.Duration, bool) {
+	status := this.breaker.status()
+	if status.State == BreakerClosed {
+		return 0, false
+	}
+	return max(status.NextAttempt.Sub(now()), time.Second), true
+}
+
+// observe opens the breaker after BreakerThreshold connection errors in a
+// row, and returns err.
+func (this *Database) observe(err error) error {
+	if err == nil || !isConnectionError(err) {
+		if this.breaker.failures.Load() != 0 {
+			this.breaker.failures.Store(0)
+		}
+		return err
+	}
+	if this.breaker.failures.Add(1) >= BreakerThreshold {
+		this.trip(err)
+	}
+	return err
+}
+
+// trip opens the breaker and reconnects in the background.
+func (this *Database) trip(err error) {
+	if generation, tripped := this.breaker.trip(err); tripped {
+		u.WarnF("Database unavailable, reconnecting in the background:\t%s\n", err.Error())
+		go this.reconnect(generation)
+	}
+}
+
+// reconnect probes the database with backoff until it answers, or until
+// the breaker is reset by Reconfigure or Close.
+func (this *Database) reconnect(generation int) {
+	for attempt := 1; ; attempt++ {
+		delay := ReconnectBackoff.delay(attempt)
+		this.breaker.mu.Lock()
+		if this.breaker.generation != generation {
+			this.breaker.mu.Unlock()
+			return
+		}
+		this.breaker.state = BreakerOpen
+		this.breaker.nextAttempt = now().Add(delay)
+		this.breaker.mu.Unlock()
+
+		sleep(delay)
+
+		this.breaker.mu.Lock()
+		if this.breaker.generation != generation {
+			this.breaker.mu.Unlock()
+			return
+		}
+		this.breaker.state = BreakerHalfOpen
+		this.breaker.attempts = attempt
+		this.breaker.mu.Unlock()
+
+		err := this.probe(generation)
+		if err == nil {
+			this.breaker.mu.Lock()
+			current := this.breaker.generation == generation
+			this.breaker.mu.Unlock()
+			if current {
+				this.breaker.reset()
+				u.InfoF("Reconnected To Database after %d attempts\n", attempt)
+				this.connected()
+			}
+			return
+		}
+
+		this.breaker.mu.Lock()
+		this.breaker.lastError = err
+		this.breaker.mu.Unlock()
+		u.WarnF("Reconnect attempt %d failed:\t%s\n", attempt, err.Error())
+	}
+}
+
+// probe pings the live pool, or opens one when there is none.
+func (this *Database) probe(generation int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), ProbeTimeout)
+	defer cancel()
+
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	// Close and Reconfigure reset the breaker while holding mu.
+	this.breaker.mu.Lock()
+	stale := this.breaker.generation != generation
+	this.breaker.mu.Unlock()
+	if stale {
+		return nil
+	}
+
+	if current := this.current.Load(); current != nil {
+		return current.PingContext(ctx)
+	}
+	opened, err := openPool(ctx, this.Config())
+	if err != nil {
+		return err
+	}
+	this.current.Store(opened)
+	this.startReplicas(opened.config)
+	return nil
+}
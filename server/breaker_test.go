@@ -0,0 +1,249 @@
+package server
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock stands in for now and sleep. Every sleep is handed to the test
+// on sleeps and lasts until the test calls wake, which also moves the clock.
+type fakeClock struct {
+	mu     sync.Mutex
+	at     time.Time
+	sleeps chan time.Duration
+	wakes  chan struct{}
+	stop   chan struct{}
+}
+
+func useFakeClock(t *testing.T) *fakeClock {
+	clock := &fakeClock{
+		at:     time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		sleeps: make(chan time.Duration),
+		wakes:  make(chan struct{}),
+		stop:   make(chan struct{}),
+	}
+	previousNow, previousSleep := now, sleep
+	now, sleep = clock.now, clock.sleep
+	t.Cleanup(func() {
+		// Lets a reconnect loop still sleeping return.
+		close(clock.stop)
+		now, sleep = previousNow, previousSleep
+	})
+	return clock
+}
+
+func (this *fakeClock) now() time.Time {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	return this.at
+}
+
+func (this *fakeClock) sleep(delay time.Duration) {
+	select {
+	case this.sleeps <- delay:
+	case <-this.stop:
+		return
+	}
+	select {
+	case <-this.wakes:
+	case <-this.stop:
+		return
+	}
+	this.mu.Lock()
+	this.at = this.at.Add(delay)
+	this.mu.Unlock()
+}
+
+// next waits for the reconnect loop to sleep.
+func (this *fakeClock) next(t *testing.T) time.Duration {
+	t.Helper()
+	select {
+	case delay := <-this.sleeps:
+		return delay
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the reconnect loop to sleep")
+		return 0
+	}
+}
+
+func (this *fakeClock) wake() {
+	this.wakes <- struct{}{}
+}
+
+// fakeOpener fails while down is set, and otherwise opens SQLite in memory.
+// It records the breaker state each attempt sees.
+type fakeOpener struct {
+	mu     sync.Mutex
+	down   error
+	states []BreakerState
+}
+
+func useFakeOpener(t *testing.T, database *Database, down error) *fakeOpener {
+	opener := &fakeOpener{down: down}
+	previous := openPool
+	openPool = func(ctx context.Context, config DatabaseConfig) (*pool, error) {
+		opener.mu.Lock()
+		defer opener.mu.Unlock()
+		opener.states = append(opener.states, database.Breaker().State)
+		if opener.down != nil {
+			return nil, opener.down
+		}
+		return previous(ctx, DatabaseConfig{Driver: Driver(SQLITE), Database: ":memory:"})
+	}
+	t.Cleanup(func() { openPool = previous })
+	return opener
+}
+
+func (this *fakeOpener) setDown(err error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.down = err
+}
+
+func (this *fakeOpener) attempts() int {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	return len(this.states)
+}
+
+func (this *fakeOpener) lastState() BreakerState {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	return this.states[len(this.states)-1]
+}
+
+func TestBreakerReconnects(t *testing.T) {
+	clock := useFakeClock(t)
+	database := &Database{}
+	opener := useFakeOpener(t, database, errors.New("connection refused"))
+	reconnected := make(chan struct{}, 1)
+	database.OnConnect(func() { reconnected <- struct{}{} })
+	t.Cleanup(database.Close)
+
+	database.Connect()
+	if status := database.Breaker(); status.State != BreakerOpen || status.LastError != "connection refused" {
+		t.Fatalf("expected a failed Connect to open the breaker, got %+v", status)
+	}
+	if _, err := database.Query(`SELECT 1`); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("expected calls to fail fast with ErrUnavailable, got %v", err)
+	}
+
+	delay := clock.next(t)
+	status := database.Breaker()
+	if status.State != BreakerOpen || !status.NextAttempt.Equal(clock.now().Add(delay)) {
+		t.Errorf("expected the next attempt in %s, got %+v", delay, status)
+	}
+	if retryAfter, down := database.Unavailable(); !down || retryAfter != max(delay, time.Second) {
+		t.Errorf("expected to retry after %s, got %s, %v", max(delay, time.Second), retryAfter, down)
+	}
+
+	clock.wake()
+	clock.next(t)
+	if state := opener.lastState(); state != BreakerHalfOpen {
+		t.Errorf("expected the attempt to run half-open, got %s", state)
+	}
+	if status := database.Breaker(); status.State != BreakerOpen || status.Attempts != 1 {
+		t.Errorf("expected a failed attempt to leave the breaker open, got %+v", status)
+	}
+
+	opener.setDown(nil)
+	clock.wake()
+	select {
+	case <-reconnected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the OnConnect hooks to run after reconnecting")
+	}
+	if status := database.Breaker(); status.State != BreakerClosed || !database.IsConnected() {
+		t.Errorf("expected the breaker to close once connected, got %+v", status)
+	}
+	if _, down := database.Unavailable(); down {
+		t.Error("expected the database to be available")
+	}
+	if err := database.Ping(context.Background()); err != nil {
+		t.Errorf("expected calls to go through, got %s", err)
+	}
+}
+
+func TestBreakerThreshold(t *testing.T) {
+	clock := useFakeClock(t)
+	database := &Database{}
+	opener := useFakeOpener(t, database, nil)
+	t.Cleanup(database.Close)
+	database.Connect()
+	reconnected := make(chan struct{}, 1)
+	database.OnConnect(func() { reconnected <- struct{}{} })
+
+	database.observe(driver.ErrBadConn)
+	database.observe(driver.ErrBadConn)
+	database.observe(errors.New("syntax error"))
+	database.observe(driver.ErrBadConn)
+	database.observe(driver.ErrBadConn)
+	database.observe(context.Canceled)
+	if status := database.Breaker(); status.State != BreakerClosed {
+		t.Fatalf("expected errors that are not in a row, or not about the connection, to keep it closed, got %+v", status)
+	}
+
+	for i := int32(0); i < BreakerThreshold; i++ {
+		database.observe(driver.ErrBadConn)
+	}
+	if status := database.Breaker(); status.State != BreakerOpen || status.LastError != driver.ErrBadConn.Error() {
+		t.Fatalf("expected %d connection errors in a row to open it, got %+v", BreakerThreshold, status)
+	}
+
+	// The live pool is pinged rather than replaced.
+	clock.next(t)
+	clock.wake()
+	select {
+	case <-reconnected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected to reconnect")
+	}
+	if status := database.Breaker(); status.State != BreakerClosed {
+		t.Errorf("expected the breaker to close, got %+v", status)
+	}
+	if opened := opener.attempts(); opened != 1 {
+		t.Errorf("expected no new pool, got %d opened", opened)
+	}
+}
+
+func TestBreakerResetStopsReconnecting(t *testing.T) {
+	clock := useFakeClock(t)
+	database := &Database{}
+	opener := useFakeOpener(t, database, errors.New("connection refused"))
+
+	database.Connect()
+	clock.next(t)
+	database.Close()
+	if status := database.Breaker(); status.State != BreakerClosed {
+		t.Errorf("expected Close to reset the breaker, got %+v", status)
+	}
+
+	clock.wake()
+	select {
+	case <-clock.sleeps:
+		t.Error("expected the reconnect loop to stop")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if attempts := opener.attempts(); attempts != 1 {
+		t.Errorf("expected no attempt after Close, got %d", attempts-1)
+	}
+}
+
+func TestBackoffCeiling(t *testing.T) {
+	backoff := Backoff{Initial: 500 * time.Millisecond, Max: 30 * time.Second}
+	for attempt := 1; attempt <= 64; attempt++ {
+		ceiling := backoff.Max
+		if attempt < 8 {
+			ceiling = min(backoff.Initial<<(attempt-1), backoff.Max)
+		}
+		for i := 0; i < 20; i++ {
+			if delay := backoff.delay(attempt); delay < ceiling/2 || delay > ceiling {
+				t.Fatalf("attempt %d: expected a delay between %s and %s, got %s", attempt, ceiling/2, ceiling, delay)
+			}
+		}
+	}
+}
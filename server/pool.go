@@ -0,0 +1,112 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"time"
+
+	"hack/backend/dialect"
+	u "hack/backend/utils"
+)
+
+var ErrNotConnected = errors.New("database is not connected")
+
+// DrainTimeout bounds how long a replaced pool waits for the requests and
+// transactions still using it before it is closed anyway.
+var DrainTimeout = 30 * time.Second
+
+// pool is one *sql.DB together with the config it was opened with. Callers
+// hold it between acquire and release, so it is only closed once they are
+// done.
+type pool struct {
+	*sql.DB
+	config  DatabaseConfig
+	dialect dialect.Dialect
+
+	mu      sync.Mutex
+	users   int
+	retired bool
+	drained chan struct{}
+}
+
+// openPool opens and pings a pool for config. Tests replace it.
+var openPool = func(ctx context.Context, config DatabaseConfig) (*pool, error) {
+	dsn, err := config.connectionString()
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open(config.Driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	config.Pool.apply(db)
+	if config.Driver == Driver(SQLITE) && config.Database == ":memory:" {
+		// Every connection would open its own empty in-memory database.
+		db.SetMaxOpenConns(1)
+	}
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &pool{DB: db, config: config, dialect: dialect.ForDriver(config.Driver), drained: make(chan struct{})}, nil
+}
+
+// acquire registers a user, false once the pool is retired.
+func (this *pool) acquire() bool {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if this.retired {
+		return false
+	}
+	this.users++
+	return true
+}
+
+func (this *pool) release() {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.users--
+	if this.retired && this.users == 0 {
+		close(this.drained)
+	}
+}
+
+// retire stops new users and closes the pool once the current ones are
+// done, or after DrainTimeout.
+func (this *pool) retire() {
+	this.mu.Lock()
+	this.retired = true
+	if this.users == 0 {
+		close(this.drained)
+	}
+	this.mu.Unlock()
+
+	select {
+	case <-this.drained:
+	case <-time.After(DrainTimeout):
+		u.WarnF("Closing database pool with requests still in progress after %s\n", DrainTimeout)
+	}
+	this.DB.Close()
+}
+
+// disconnected and unavailable stand in for the pool while there is none or
+// the breaker is open, so queries fail with the error at once.
+var (
+	disconnected = failingPool(ErrNotConnected)
+	unavailable  = failingPool(ErrUnavailable)
+)
+
+func failingPool(err error) *pool {
+	return &pool{DB: sql.OpenDB(failing{err}), dialect: dialect.ForDriver(""), drained: make(chan struct{})}
+}
+
+type failing struct{ err error }
+
+func (this failing) Connect(context.Context) (driver.Conn, error) { return nil, this.err }
+func (this failing) Driver() driver.Driver                        { return this }
+func (this failing) Open(string) (driver.Conn, error)             { return nil, this.err }
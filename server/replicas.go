@@ -0,0 +1,200 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ReplicaCheckInterval is how often replicas are health checked.
+var ReplicaCheckInterval = 5 * time.Second
+
+// replica is a read replica with its own pool, breaker and health.
+type replica struct {
+	*Database
+	healthy   atomic.Bool
+	lastError atomic.Pointer[string]
+	checkedAt atomic.Pointer[time.Time]
+}
+
+// check connects the replica when needed and pings it.
+func (this *replica) check() {
+	this.Connect()
+
+	var err error
+	if _, down := this.Unavailable(); down {
+		err = fmt.Errorf("%w: %s", ErrUnavailable, this.Breaker().LastError)
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), ProbeTimeout)
+		err = this.Ping(ctx)
+		cancel()
+	}
+
+	now := time.Now()
+	this.checkedAt.Store(&now)
+	this.healthy.Store(err == nil)
+	if err != nil {
+		message := err.Error()
+		this.lastError.Store(&message)
+	}
+}
+
+// replicaSet is the replicas of one primary config, checked until close.
+type replicaSet struct {
+	replicas []*replica
+	next     atomic.Uint64
+	stop     chan struct{}
+	done     sync.WaitGroup
+}
+
+func newReplicaSet(primary DatabaseConfig) *replicaSet {
+	set := &replicaSet{stop: make(chan struct{})}
+	for _, config := range primary.Replicas {
+		database := &Database{}
+		database.Configure(config.replicaOf(primary))
+		set.replicas = append(set.replicas, &replica{Database: database})
+	}
+
+	set.done.Add(1)
+	go set.watch()
+	return set
+}
+
+// watch checks every replica right away, then every ReplicaCheckInterval.
+func (this *replicaSet) watch() {
+	defer this.done.Done()
+	ticker := time.NewTicker(ReplicaCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		for _, replica := range this.replicas {
+			replica.check()
+		}
+		select {
+		case <-this.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (this *replicaSet) close() {
+	close(this.stop)
+	this.done.Wait()
+	for _, replica := range this.replicas {
+		replica.Close()
+	}
+}
+
+// replicaOf fills the fields a replica leaves empty from its primary, so a
+// replica can be given as just a host.
+func (this DatabaseConfig) replicaOf(primary DatabaseConfig) DatabaseConfig {
+	inherit := func(field *string, value string) {
+		if *field == "" {
+			*field = value
+		}
+	}
+	inherit(&this.Driver, primary.Driver)
+	inherit(&this.User, primary.User)
+	inherit(&this.Password, primary.Password)
+	inherit(&this.Database, primary.Database)
+	inherit(&this.SSL, primary.SSL)
+	inherit(&this.SSLRootCert, primary.SSLRootCert)
+	inherit(&this.SSLCert, primary.SSLCert)
+	inherit(&this.SSLKey, primary.SSLKey)
+	inherit(&this.ApplicationName, primary.ApplicationName)
+	inherit(&this.SearchPath, primary.SearchPath)
+	if this.Port == 0 {
+		this.Port = primary.Port
+	}
+	if this.Params == nil {
+		this.Params = primary.Params
+	}
+	if this.Pool == (PoolConfig{}) {
+		this.Pool = primary.Pool
+	}
+	this.Replicas = nil
+	return this
+}
+
+// startReplicas replaces the replica set with one for config.
+func (this *Database) startReplicas(config DatabaseConfig) {
+	var set *replicaSet
+	if len(config.Replicas) > 0 {
+		set = newReplicaSet(config)
+	}
+	if old := this.replicas.Swap(set); old != nil {
+		go old.close()
+	}
+}
+
+func (this *Database) stopReplicas() {
+	if old := this.replicas.Swap(nil); old != nil {
+		old.close()
+	}
+}
+
+type primaryKey struct{}
+
+// WithPrimary pins the reads made with ctx to the primary, e.g. right after
+// the client wrote and a replica may lag behind.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// Reader is where reads go: the healthy replicas in turn, or the primary
+// when there are none or ctx is pinned to it with WithPrimary.
+func (this *Database) Reader(ctx context.Context) *Database {
+	set := this.replicas.Load()
+	if set == nil || ctx.Value(primaryKey{}) != nil {
+		return this
+	}
+
+	count := uint64(len(set.replicas))
+	start := set.next.Add(1)
+	for i := uint64(0); i < count; i++ {
+		replica := set.replicas[(start+i)%count]
+		if _, down := replica.Unavailable(); replica.healthy.Load() && !down {
+			return replica.Database
+		}
+	}
+	return this
+}
+
+// ReplicaStatus describes a replica for the admin pages.
+type ReplicaStatus struct {
+	Host      string
+	Healthy   bool
+	CheckedAt time.Time
+	LastError string
+	Breaker   BreakerState
+	Stats     sql.DBStats
+}
+
+func (this *Database) Replicas() []ReplicaStatus {
+	set := this.replicas.Load()
+	if set == nil {
+		return nil
+	}
+
+	statuses := make([]ReplicaStatus, 0, len(set.replicas))
+	for _, replica := range set.replicas {
+		status := ReplicaStatus{
+			Host:    replica.Config().Host,
+			Healthy: replica.healthy.Load(),
+			Breaker: replica.Breaker().State,
+		}
+		if checkedAt := replica.checkedAt.Load(); checkedAt != nil {
+			status.CheckedAt = *checkedAt
+		}
+		if lastError := replica.lastError.Load(); lastError != nil {
+			status.LastError = *lastError
+		}
+		status.Stats, _ = replica.Stats()
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
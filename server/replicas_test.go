@@ -0,0 +1,128 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withReplicas gives database a set of n healthy replicas that are never
+// checked, so the test decides their health.
+func withReplicas(database *Database, n int) []*replica {
+	set := &replicaSet{stop: make(chan struct{})}
+	for i := 0; i < n; i++ {
+		replica := &replica{Database: &Database{}}
+		replica.healthy.Store(true)
+		set.replicas = append(set.replicas, replica)
+	}
+	database.replicas.Store(set)
+	return set.replicas
+}
+
+// reads counts where n reads go, -1 for the primary.
+func reads(database *Database, replicas []*replica, ctx context.Context, n int) map[int]int {
+	counts := map[int]int{}
+	for i := 0; i < n; i++ {
+		reader := database.Reader(ctx)
+		index := -1
+		for j, replica := range replicas {
+			if reader == replica.Database {
+				index = j
+			}
+		}
+		counts[index]++
+	}
+	return counts
+}
+
+func TestReaderRoundRobin(t *testing.T) {
+	database := &Database{}
+	if reader := database.Reader(context.Background()); reader != database {
+		t.Errorf("expected the primary without replicas")
+	}
+
+	replicas := withReplicas(database, 3)
+	if counts := reads(database, replicas, context.Background(), 9); !reflect.DeepEqual(counts, map[int]int{0: 3, 1: 3, 2: 3}) {
+		t.Errorf("expected reads to take turns, got %v", counts)
+	}
+	if counts := reads(database, replicas, WithPrimary(context.Background()), 3); !reflect.DeepEqual(counts, map[int]int{-1: 3}) {
+		t.Errorf("expected WithPrimary to pin reads to the primary, got %v", counts)
+	}
+}
+
+func TestReaderSkipsUnhealthy(t *testing.T) {
+	database := &Database{}
+	replicas := withReplicas(database, 3)
+
+	// Its turns go to the next healthy one.
+	replicas[1].healthy.Store(false)
+	if counts := reads(database, replicas, context.Background(), 9); !reflect.DeepEqual(counts, map[int]int{0: 3, 2: 6}) {
+		t.Errorf("expected the unhealthy replica to be skipped, got %v", counts)
+	}
+
+	replicas[2].breaker.trip(errors.New("connection refused"))
+	if counts := reads(database, replicas, context.Background(), 4); !reflect.DeepEqual(counts, map[int]int{0: 4}) {
+		t.Errorf("expected a replica with an open breaker to be skipped, got %v", counts)
+	}
+
+	replicas[0].healthy.Store(false)
+	if counts := reads(database, replicas, context.Background(), 2); !reflect.DeepEqual(counts, map[int]int{-1: 2}) {
+		t.Errorf("expected the primary once no replica is healthy, got %v", counts)
+	}
+}
+
+func TestReplicaCheck(t *testing.T) {
+	clock := useFakeClock(t)
+	replica := &replica{Database: &Database{}}
+	opener := useFakeOpener(t, replica.Database, errors.New("connection refused"))
+	t.Cleanup(replica.Close)
+
+	replica.check()
+	if replica.healthy.Load() {
+		t.Error("expected a replica that cannot connect to be unhealthy")
+	}
+	if lastError := replica.lastError.Load(); lastError == nil || !strings.Contains(*lastError, "connection refused") {
+		t.Errorf("expected the connection error, got %v", lastError)
+	}
+
+	// Recovers once the background reconnect gets through.
+	opener.setDown(nil)
+	reconnected := make(chan struct{}, 1)
+	replica.OnConnect(func() { reconnected <- struct{}{} })
+	clock.next(t)
+	clock.wake()
+	select {
+	case <-reconnected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the replica to reconnect")
+	}
+	replica.check()
+	if !replica.healthy.Load() || replica.checkedAt.Load() == nil {
+		t.Error("expected the replica to be healthy again")
+	}
+}
+
+func TestReplicaOf(t *testing.T) {
+	primary := DatabaseConfig{
+		Driver: "pgx", Host: "primary", Port: 5432, User: "app", Password: "secret", Database: "prod", SSL: "require",
+		Params: map[string]string{"connect_timeout": "5"}, Pool: PoolConfig{MaxOpenConns: 10},
+		Replicas: []DatabaseConfig{{Host: "replica"}},
+	}
+
+	inherited := primary.Replicas[0].replicaOf(primary)
+	expected := DatabaseConfig{
+		Driver: "pgx", Host: "replica", Port: 5432, User: "app", Password: "secret", Database: "prod", SSL: "require",
+		Params: map[string]string{"connect_timeout": "5"}, Pool: PoolConfig{MaxOpenConns: 10},
+	}
+	if !reflect.DeepEqual(inherited, expected) {
+		t.Errorf("expected %+v, got %+v", expected, inherited)
+	}
+
+	own := DatabaseConfig{Host: "replica", Port: 6432, User: "reader", Pool: PoolConfig{MaxOpenConns: 2}}.replicaOf(primary)
+	if own.Port != 6432 || own.User != "reader" || own.Password != "secret" || own.Pool.MaxOpenConns != 2 {
+		t.Errorf("expected the replica's own settings to be kept, got %+v", own)
+	}
+}
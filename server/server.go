@@ -1,86 +1,436 @@
 package server
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
 	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"hack/backend/dialect"
 	u "hack/backend/utils"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
 )
 
 type DatabaseDriver int
 
 const (
 	POSTGRESQL DatabaseDriver = iota
+	SQLITE
+	MYSQL
 )
 
 var DatabaseDrivers = map[DatabaseDriver]string{
 	POSTGRESQL: "pgx",
+	SQLITE:     "sqlite",
+	MYSQL:      "mysql",
+}
+
+// mysqlTLS maps the Postgres sslmode values used in DatabaseConfig.SSL to
+// the tls parameter of the MySQL driver.
+var mysqlTLS = map[string]string{
+	"":            "false",
+	"disable":     "false",
+	"allow":       "preferred",
+	"prefer":      "preferred",
+	"require":     "skip-verify",
+	"verify-ca":   "true",
+	"verify-full": "true",
 }
 
 func Driver(driver DatabaseDriver) string {
 	return DatabaseDrivers[driver]
 }
 
+// DatabaseConfig describes a connection. Host may list several
+// comma separated hosts, each with an optional :port, or be the path of a
+// unix socket. Params holds extra driver parameters.
 type DatabaseConfig struct {
-	Host     string
-	Port     uint16
-	User     string
-	Password string
-	Database string
-	SSL      string
-	Driver   string
+	Host            string
+	Port            uint16
+	User            string
+	Password        string
+	Database        string
+	SSL             string
+	SSLRootCert     string
+	SSLCert         string
+	SSLKey          string
+	ApplicationName string
+	SearchPath      string
+	Params          map[string]string
+	Driver          string
+	Pool            PoolConfig
+	// Replicas serve reads, fields they leave empty come from the primary.
+	Replicas []DatabaseConfig
+}
+
+// PoolConfig sizes the connection pool. Zero leaves the database/sql
+// default: no limit on open connections or their age, two idle ones.
+type PoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+func (this PoolConfig) apply(db *sql.DB) {
+	if this.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(this.MaxOpenConns)
+	}
+	if this.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(this.MaxIdleConns)
+	}
+	if this.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(this.ConnMaxLifetime)
+	}
+	if this.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(this.ConnMaxIdleTime)
+	}
 }
 
+// Database is the handle the handlers share. It is safe for concurrent
+// use: Reconfigure swaps in a new pool while requests keep running on the
+// old one until they finish.
 type Database struct {
-	db          *sql.DB
-	IsConnected bool
-	Config      DatabaseConfig
+	mu       sync.Mutex // serializes Connect, Reconfigure and Close
+	config   atomic.Pointer[DatabaseConfig]
+	current  atomic.Pointer[pool]
+	breaker  breaker
+	replicas atomic.Pointer[replicaSet]
+
+	hooks     sync.Mutex
+	onConnect []func()
 }
 
-func (this *Database) Connect() {
-	if this.IsConnected {
-		return
+// OnConnect adds a hook run after every successful Connect, Reconfigure and
+// reconnect, for setup that needs the database such as loading stored
+// models. Hooks run outside the connection lock, so they may query.
+func (this *Database) OnConnect(hook func()) {
+	this.hooks.Lock()
+	defer this.hooks.Unlock()
+	this.onConnect = append(this.onConnect, hook)
+}
+
+// connected runs the OnConnect hooks.
+func (this *Database) connected() {
+	this.hooks.Lock()
+	hooks := append([]func(){}, this.onConnect...)
+	this.hooks.Unlock()
+	for _, hook := range hooks {
+		hook()
 	}
+}
 
-	var err error
+// Configure sets the config the next Connect uses, without touching a live
+// pool.
+func (this *Database) Configure(config DatabaseConfig) {
+	this.config.Store(&config)
+}
 
-	var connectionString = fmt.Sprintf("user=%s password=%s dbname=%s sslmode=%s host=%s port=%d sslmode=%s", this.Config.User, this.Config.Password, this.Config.Database, this.Config.SSL, this.Config.Host, this.Config.Port, this.Config.SSL)
+// Config is the config of the live pool, or the one Connect will use.
+func (this *Database) Config() DatabaseConfig {
+	if config := this.config.Load(); config != nil {
+		return *config
+	}
+	return DatabaseConfig{}
+}
+
+func (this *Database) IsConnected() bool {
+	return this.current.Load() != nil
+}
+
+// Connect makes one attempt to connect. When it fails the breaker opens and
+// reconnecting continues in the background, so further calls return at once.
+func (this *Database) Connect() {
+	if this.IsConnected() || this.breaker.open() {
+		return
+	}
+	if this.open() {
+		this.connected()
+	}
+}
 
-	this.db, err = sql.Open(this.Config.Driver, connectionString)
-	if err != nil || this.db == nil {
-		this.db = nil
-		u.Error("Failed to Call Connect to Database!")
-	} else {
-		u.Info("Called Connect to Database")
+// open is Connect under the connection lock, true when it connected.
+func (this *Database) open() bool {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if this.IsConnected() || this.breaker.open() {
+		return false
 	}
 
-	err = this.db.Ping()
+	u.Info("Called Connect to Database")
+	opened, err := openPool(context.Background(), this.Config())
 	if err != nil {
-		this.db = nil
-		this.IsConnected = false
 		u.ErrorF("Failed to Connect To Database!:\t%s\n", err.Error())
-		return
+		this.trip(err)
+		return false
 	}
 
 	u.Info("Successfully Connected To Database!")
-	this.IsConnected = true
+	this.current.Store(opened)
+	this.startReplicas(opened.config)
+	return true
+}
+
+// Reconfigure opens and verifies a pool for config, then swaps it in. The
+// old pool drains in the background. When the new one cannot connect the
+// old pool keeps serving and the error is returned, though with no live
+// pool the config is kept for the next Connect.
+func (this *Database) Reconfigure(ctx context.Context, config DatabaseConfig) error {
+	this.mu.Lock()
+	opened, err := openPool(ctx, config)
+	if err != nil {
+		if !this.IsConnected() {
+			this.Configure(config)
+		}
+		this.mu.Unlock()
+		return err
+	}
+
+	this.Configure(config)
+	this.breaker.reset()
+	if old := this.current.Swap(opened); old != nil {
+		go old.retire()
+	}
+	this.startReplicas(config)
+	this.mu.Unlock()
+	u.Info("Switched To The New Database Connection")
+
+	this.connected()
+	return nil
+}
+
+// connectionString builds the data source name for the driver. For SQLite,
+// Database is the path of the database file.
+func (this DatabaseConfig) connectionString() (string, error) {
+	switch this.Driver {
+	case Driver(SQLITE):
+		pragmas := url.Values{"_pragma": {"foreign_keys(1)", "busy_timeout(5000)", "journal_mode(WAL)"}}
+		return "file:" + this.Database + "?" + pragmas.Encode(), nil
+	case Driver(MYSQL):
+		return this.mysqlDSN()
+	}
+	return this.postgresDSN(), nil
+}
+
+// Dialect is the SQL dialect spoken by the configured driver.
+func (this *Database) Dialect() dialect.Dialect {
+	return dialect.ForDriver(this.Config().Driver)
 }
 
+// Stats reports the connection pool, false when not connected.
+func (this *Database) Stats() (sql.DBStats, bool) {
+	current := this.current.Load()
+	if current == nil {
+		return sql.DBStats{}, false
+	}
+	return current.Stats(), true
+}
+
+// Close waits for the requests using the pool, up to DrainTimeout, and
+// closes it.
 func (this *Database) Close() {
-	this.db.Close()
-	this.IsConnected = false
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.breaker.reset()
+	this.stopReplicas()
+	if current := this.current.Swap(nil); current != nil {
+		current.retire()
+	}
 	// u.Info("Closing Database Connection")
 }
 
-func (this *Database) Query(query string, args ...any) (*sql.Rows, error) {
-	return this.db.Query(query, args...)
+// acquire returns the live pool, registered as in use until release, or a
+// stand-in failing with ErrNotConnected or ErrUnavailable.
+func (this *Database) acquire() *pool {
+	for {
+		current := this.current.Load()
+		if current == nil {
+			current = disconnected
+		}
+		if this.breaker.open() {
+			current = unavailable
+		}
+		if current.acquire() {
+			return current
+		}
+		// Retired after the load, its replacement is already stored.
+	}
+}
+
+// Query, QueryRow and Exec take canonical SQL, with ? placeholders and
+// double-quoted identifiers, and rebind it for the dialect.
+func (this *Database) Query(query string, args ...any) (*Rows, error) {
+	return this.QueryContext(context.Background(), query, args...)
+}
+
+// Begin starts a transaction, which keeps its pool open until it commits or
+// rolls back.
+func (this *Database) Begin() (*Tx, error) {
+	p := this.acquire()
+	tx, err := p.Begin()
+	if err != nil {
+		p.release()
+		return nil, this.observe(err)
+	}
+	return &Tx{Tx: tx, Dialect: p.dialect, lease: lease{pool: p}}, nil
+}
+
+func (this *Database) Ping(ctx context.Context) error {
+	p := this.acquire()
+	defer p.release()
+	return this.observe(p.PingContext(ctx))
+}
+
+// Conn reserves a single connection, for work that needs session state
+// such as advisory locks. It keeps its pool open until it is closed.
+func (this *Database) Conn(ctx context.Context) (*Conn, error) {
+	p := this.acquire()
+	conn, err := p.Conn(ctx)
+	if err != nil {
+		p.release()
+		return nil, this.observe(err)
+	}
+	return &Conn{Conn: conn, lease: lease{pool: p}}, nil
+}
+
+// QueryContext returns rows that keep their pool open until they are closed
+// or read to the end.
+func (this *Database) QueryContext(ctx context.Context, query string, args ...any) (*Rows, error) {
+	p := this.acquire()
+	rows, err := p.QueryContext(ctx, dialect.Rebind(p.dialect, query), dialect.Args(p.dialect, args)...)
+	if err != nil {
+		p.release()
+		return nil, this.observe(err)
+	}
+	return &Rows{Rows: rows, lease: lease{pool: p}}, nil
+}
+
+func (this *Database) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	p := this.acquire()
+	defer p.release()
+	result, err := p.ExecContext(ctx, dialect.Rebind(p.dialect, query), dialect.Args(p.dialect, args)...)
+	return result, this.observe(err)
+}
+
+// QueryRow returns a row that keeps its pool open until it is scanned.
+func (this *Database) QueryRow(query string, args ...any) *Row {
+	p := this.acquire()
+	row := p.QueryRow(dialect.Rebind(p.dialect, query), dialect.Args(p.dialect, args)...)
+	this.observe(row.Err())
+	return &Row{Row: row, lease: lease{pool: p}}
 }
 
 func (this *Database) Exec(query string, args ...any) (sql.Result, error) {
-	return this.db.Exec(query, args...)
+	p := this.acquire()
+	defer p.release()
+	result, err := p.Exec(dialect.Rebind(p.dialect, query), dialect.Args(p.dialect, args)...)
+	return result, this.observe(err)
+}
+
+// lease holds a pool from acquire until its holder is done with it.
+type lease struct {
+	pool *pool
+	done sync.Once
+}
+
+// finish releases the pool the first time it is called.
+func (this *lease) finish() {
+	this.done.Do(func() {
+		if this.pool != nil {
+			this.pool.release()
+		}
+	})
+}
+
+// Rows are query results that release their pool once closed, or once Next
+// runs out, which closes them too.
+type Rows struct {
+	*sql.Rows
+	lease
+}
+
+func (this *Rows) Next() bool {
+	if this.Rows.Next() {
+		return true
+	}
+	this.finish()
+	return false
+}
+
+func (this *Rows) Close() error {
+	defer this.finish()
+	return this.Rows.Close()
+}
+
+// Row releases its pool once scanned.
+type Row struct {
+	*sql.Row
+	lease
+}
+
+func (this *Row) Scan(dest ...any) error {
+	defer this.finish()
+	return this.Row.Scan(dest...)
+}
+
+// Conn is a reserved connection that releases its pool once closed.
+type Conn struct {
+	*sql.Conn
+	lease
+}
+
+func (this *Conn) Close() error {
+	defer this.finish()
+	return this.Conn.Close()
+}
+
+// Tx is a transaction that rebinds canonical SQL like Database does.
+type Tx struct {
+	*sql.Tx
+	Dialect dialect.Dialect
+
+	lease
+}
+
+func (this *Tx) Commit() error {
+	defer this.finish()
+	return this.Tx.Commit()
+}
+
+func (this *Tx) Rollback() error {
+	defer this.finish()
+	return this.Tx.Rollback()
+}
+
+// Query and QueryContext return Rows like Database does; the transaction
+// already holds the pool.
+func (this *Tx) Query(query string, args ...any) (*Rows, error) {
+	return this.QueryContext(context.Background(), query, args...)
+}
+
+func (this *Tx) QueryContext(ctx context.Context, query string, args ...any) (*Rows, error) {
+	rows, err := this.Tx.QueryContext(ctx, dialect.Rebind(this.Dialect, query), dialect.Args(this.Dialect, args)...)
+	if err != nil {
+		return nil, err
+	}
+	return &Rows{Rows: rows}, nil
+}
+
+func (this *Tx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return this.Tx.ExecContext(ctx, dialect.Rebind(this.Dialect, query), dialect.Args(this.Dialect, args)...)
+}
+
+func (this *Tx) QueryRow(query string, args ...any) *sql.Row {
+	return this.Tx.QueryRow(dialect.Rebind(this.Dialect, query), dialect.Args(this.Dialect, args)...)
+}
+
+func (this *Tx) Exec(query string, args ...any) (sql.Result, error) {
+	return this.Tx.Exec(dialect.Rebind(this.Dialect, query), dialect.Args(this.Dialect, args)...)
 }
 
 func IsURL_Index(r *http.Request) bool {
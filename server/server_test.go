@@ -0,0 +1,131 @@
+package server
+
+import (
+	"context"
+	"testing"
+)
+
+func openSQLite(t *testing.T) *Database {
+	t.Helper()
+	database := &Database{}
+	database.Configure(DatabaseConfig{Driver: Driver(SQLITE), Database: ":memory:"})
+	database.Connect()
+	if !database.IsConnected() {
+		t.Fatal("failed to open SQLite")
+	}
+	t.Cleanup(database.Close)
+	return database
+}
+
+func (this *pool) inUse() int {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	return this.users
+}
+
+// Rows, Row and Conn hold the pool, so a Reconfigure waits for them before
+// closing it.
+func TestPoolHeldUntilDone(t *testing.T) {
+	database := openSQLite(t)
+	p := database.current.Load()
+
+	tests := []struct {
+		name string
+		open func() (done func())
+	}{
+		{"rows closed", func() func() {
+			rows, err := database.Query(`SELECT 1 UNION ALL SELECT 2`)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return func() { rows.Close() }
+		}},
+		{"rows read to the end", func() func() {
+			rows, err := database.QueryContext(context.Background(), `SELECT 1`)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return func() {
+				for rows.Next() {
+				}
+			}
+		}},
+		{"row scanned", func() func() {
+			row := database.QueryRow(`SELECT ?`, 1)
+			return func() {
+				var one int
+				if err := row.Scan(&one); err != nil || one != 1 {
+					t.Errorf("expected 1, got %d, %v", one, err)
+				}
+			}
+		}},
+		{"conn closed", func() func() {
+			conn, err := database.Conn(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			return func() { conn.Close() }
+		}},
+		{"tx committed", func() func() {
+			tx, err := database.Begin()
+			if err != nil {
+				t.Fatal(err)
+			}
+			return func() { tx.Commit() }
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			done := test.open()
+			if users := p.inUse(); users != 1 {
+				t.Errorf("expected the pool to be held, got %d users", users)
+			}
+			done()
+			if users := p.inUse(); users != 0 {
+				t.Errorf("expected the pool to be released, got %d users", users)
+			}
+		})
+	}
+
+	if _, err := database.Query(`SELECT * FROM missing`); err == nil {
+		t.Error("expected an error")
+	}
+	if users := p.inUse(); users != 0 {
+		t.Errorf("expected a failed query to release the pool, got %d users", users)
+	}
+}
+
+func TestOnConnect(t *testing.T) {
+	database := &Database{}
+	database.Configure(DatabaseConfig{Driver: Driver(SQLITE), Database: ":memory:"})
+	t.Cleanup(database.Close)
+
+	calls := 0
+	database.OnConnect(func() {
+		// Hooks may query.
+		var one int
+		if err := database.QueryRow(`SELECT 1`).Scan(&one); err != nil {
+			t.Errorf("failed to query from the hook: %s", err)
+		}
+		calls++
+	})
+
+	database.Connect()
+	database.Connect()
+	if calls != 1 {
+		t.Errorf("expected the hook to run once on Connect, got %d", calls)
+	}
+	if err := database.Reconfigure(context.Background(), database.Config()); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Errorf("expected the hook to run on Reconfigure, got %d", calls)
+	}
+	if err := database.Reconfigure(context.Background(), DatabaseConfig{Driver: "missing"}); err == nil {
+		t.Fatal("expected an error")
+	}
+	if calls != 2 {
+		t.Errorf("expected the hook not to run on a failed Reconfigure, got %d", calls)
+	}
+}
@@ -0,0 +1,6 @@
+package main
+
+import "time"
+
+// How long soft-deleted rows are kept before they can be purged.
+var SoftDeleteRetention = 30 * 24 * time.Hour
@@ -0,0 +1,653 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"hack/backend/models"
+)
+
+// Memory keeps rows in process memory. It is safe for concurrent use and
+// follows the SQL storage's where, sort and pagination semantics, which
+// makes it handy for tests and for trying the adapter without a database.
+type Memory struct {
+	mu     *sync.RWMutex // nil for the view inside Transaction
+	tables map[string][]Row
+}
+
+func NewMemory() *Memory {
+	return &Memory{mu: &sync.RWMutex{}, tables: map[string][]Row{}}
+}
+
+func (this *Memory) lock() func() {
+	if this.mu == nil {
+		return func() {}
+	}
+	this.mu.Lock()
+	return this.mu.Unlock
+}
+
+func (this *Memory) rlock() func() {
+	if this.mu == nil {
+		return func() {}
+	}
+	this.mu.RLock()
+	return this.mu.RUnlock
+}
+
+// project copies row with exactly the model's columns, as a SELECT would.
+func project(model *models.Model, row Row) Row {
+	result := make(Row, len(model.Fields))
+	for _, field := range model.Fields {
+		result[field.Column] = row[field.Column]
+	}
+	return result
+}
+
+// defaultValue evaluates the SQL default expressions models use.
+func defaultValue(field *models.Field) (interface{}, bool) {
+	def := strings.TrimSpace(field.Default)
+	switch {
+	case def == "":
+		return nil, false
+	case strings.EqualFold(def, "NULL"):
+		return nil, true
+	case strings.EqualFold(def, "CURRENT_TIMESTAMP"), strings.EqualFold(def, "now()"):
+		return time.Now().UTC(), true
+	case strings.EqualFold(def, "TRUE"), strings.EqualFold(def, "FALSE"):
+		return strings.EqualFold(def, "TRUE"), true
+	case len(def) >= 2 && def[0] == '\'' && def[len(def)-1] == '\'':
+		value, err := field.Coerce(strings.ReplaceAll(def[1:len(def)-1], "''", "'"))
+		return value, err == nil
+	}
+	if n, err := strconv.ParseFloat(def, 64); err == nil {
+		value, err := field.Coerce(n)
+		return value, err == nil
+	}
+	return nil, false
+}
+
+func (this *Memory) Create(ctx context.Context, model *models.Model, data Row) (Row, error) {
+	defer this.lock()()
+
+	rows := this.tables[model.Table]
+	row := make(Row, len(model.Fields))
+	for _, field := range model.Fields {
+		value, ok := data[field.Column]
+		if !ok {
+			value, ok = defaultValue(field)
+		}
+		if !ok && field.PrimaryKey && isInteger(field.Type) {
+			value = nextID(rows, field.Column)
+		}
+		if value == nil && !field.Nullable {
+			return nil, violation(NotNull, "NOT NULL constraint failed: %s.%s", model.Table, field.Column)
+		}
+		row[field.Column] = value
+	}
+
+	if err := checkUnique(model, rows, row, -1); err != nil {
+		return nil, err
+	}
+	this.tables[model.Table] = append(rows, row)
+	return project(model, row), nil
+}
+
+func isInteger(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// nextID mimics an auto-increment key.
+func nextID(rows []Row, column string) int64 {
+	var max int64
+	for _, row := range rows {
+		if id, ok := toInt(row[column]); ok && id > max {
+			max = id
+		}
+	}
+	return max + 1
+}
+
+// checkUnique reports a row that would collide with another on its primary
+// key or a unique constraint. skip is the index of the row being replaced.
+// Soft-deleted rows only keep their primary key, as with the partial unique
+// indexes of the SQL storage.
+func checkUnique(model *models.Model, rows []Row, row Row, skip int) error {
+	var keys [][]string
+	groups := map[string][]string{}
+	var primary []string
+	for _, field := range model.Fields {
+		if field.PrimaryKey {
+			primary = append(primary, field.Column)
+		}
+		if field.Unique {
+			keys = append(keys, []string{field.Column})
+		}
+		for _, group := range field.UniqueGroups {
+			groups[group] = append(groups[group], field.Column)
+		}
+	}
+	for _, group := range groups {
+		keys = append(keys, group)
+	}
+
+	for i, other := range rows {
+		if i == skip {
+			continue
+		}
+		if len(primary) > 0 && sameKey(primary, row, other) {
+			return violation(Unique, "UNIQUE constraint failed: %s.%s", model.Table, strings.Join(primary, ", "))
+		}
+		if !visible(model, row, false) || !visible(model, other, false) {
+			continue
+		}
+		for _, columns := range keys {
+			if sameKey(columns, row, other) {
+				return violation(Unique, "UNIQUE constraint failed: %s.%s", model.Table, strings.Join(columns, ", "))
+			}
+		}
+	}
+	return nil
+}
+
+// sameKey compares like a unique index, where NULLs never collide.
+func sameKey(columns []string, a Row, b Row) bool {
+	for _, column := range columns {
+		if a[column] == nil || b[column] == nil || !equal(a[column], b[column]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (this *Memory) FindOne(ctx context.Context, model *models.Model, query Query) (Row, error) {
+	query.Limit = 1
+	rows, err := this.FindMany(ctx, model, query)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, ErrNotFound
+	}
+	return rows[0], nil
+}
+
+func (this *Memory) FindMany(ctx context.Context, model *models.Model, query Query) ([]Row, error) {
+	clauses, err := parseWhere(model, query.Where)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkQuery(model, query); err != nil {
+		return nil, err
+	}
+	defer this.rlock()()
+
+	var results []Row
+	for _, row := range this.tables[model.Table] {
+		if matches(clauses, row) && visible(model, row, query.IncludeDeleted) {
+			results = append(results, project(model, row))
+		}
+	}
+
+	sortRows(results, query.SortBy)
+
+	if query.Offset >= len(results) {
+		return nil, nil
+	}
+	results = results[query.Offset:]
+	if query.Limit > 0 && query.Limit < len(results) {
+		results = results[:query.Limit]
+	}
+	return results, nil
+}
+
+func (this *Memory) Count(ctx context.Context, model *models.Model, query Query) (int64, error) {
+	clauses, err := parseWhere(model, query.Where)
+	if err != nil {
+		return 0, err
+	}
+	defer this.rlock()()
+
+	var count int64
+	for _, row := range this.tables[model.Table] {
+		if matches(clauses, row) && visible(model, row, query.IncludeDeleted) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func visible(model *models.Model, row Row, includeDeleted bool) bool {
+	return !model.Options.SoftDelete || includeDeleted || row[models.DeletedAtColumn] == nil
+}
+
+func (this *Memory) Update(ctx context.Context, model *models.Model, where []Where, update Row, ifMatch *IfMatch) (int64, error) {
+	clauses, err := parseWhere(model, where)
+	if err != nil {
+		return 0, err
+	}
+	if err := checkUpdate(model, update); err != nil {
+		return 0, err
+	}
+
+	// Optimistic concurrency: only apply when the row is still at the expected version.
+	var expected func(row Row) bool
+	if ifMatch != nil {
+		switch {
+		case ifMatch.Version != nil:
+			if _, ok := model.Field("version"); !ok {
+				return 0, invalid("Model '%s' has no version field", model.Name)
+			}
+			expected = func(row Row) bool {
+				version, ok := toInt(row["version"])
+				return ok && version == *ifMatch.Version
+			}
+			if _, ok := update["version"]; !ok {
+				update = copyRow(update)
+				update["version"] = nil // incremented per row below
+			}
+		case ifMatch.UpdatedAt != nil:
+			expected = func(row Row) bool {
+				return row["updatedAt"] != nil && equal(row["updatedAt"], *ifMatch.UpdatedAt)
+			}
+		default:
+			return 0, invalid("ifMatch requires updatedAt or version")
+		}
+	}
+
+	defer this.lock()()
+
+	rows := this.tables[model.Table]
+	var matched []int
+	found := false
+	for i, row := range rows {
+		if !matches(clauses, row) {
+			continue
+		}
+		found = true
+		if expected == nil || expected(row) {
+			matched = append(matched, i)
+		}
+	}
+
+	if ifMatch != nil && len(matched) == 0 {
+		if !found {
+			return 0, ErrNotFound
+		}
+		for _, row := range rows {
+			if matches(clauses, row) {
+				return 0, &ConflictError{Current: project(model, row)}
+			}
+		}
+	}
+
+	return this.apply(model, matched, func(row Row) {
+		for column, value := range update {
+			if _, ok := model.Field(column); !ok {
+				continue
+			}
+			if column == "version" && value == nil && ifMatch != nil && ifMatch.Version != nil {
+				version, _ := toInt(row["version"])
+				value = version + 1
+			}
+			row[column] = value
+		}
+	})
+}
+
+// checkUpdate refuses empty updates and unknown columns, which the SQL
+// storage would not be able to render either.
+func checkUpdate(model *models.Model, update Row) error {
+	count := 0
+	for column := range update {
+		if _, ok := model.Field(column); !ok {
+			continue
+		}
+		count++
+	}
+	if count == 0 {
+		return invalid("nothing to update")
+	}
+	return nil
+}
+
+func copyRow(row Row) Row {
+	result := make(Row, len(row))
+	for column, value := range row {
+		result[column] = value
+	}
+	return result
+}
+
+// apply changes copies of the rows at indexes and stores them only when
+// no unique constraint breaks, so a failed update leaves nothing behind.
+// The caller holds the lock.
+func (this *Memory) apply(model *models.Model, indexes []int, change func(row Row)) (int64, error) {
+	rows := append([]Row(nil), this.tables[model.Table]...)
+	for _, i := range indexes {
+		row := copyRow(rows[i])
+		change(row)
+		for _, field := range model.Fields {
+			if field.Column != "" && row[field.Column] == nil && !field.Nullable {
+				return 0, violation(NotNull, "NOT NULL constraint failed: %s.%s", model.Table, field.Column)
+			}
+		}
+		rows[i] = row
+	}
+	for _, i := range indexes {
+		if err := checkUnique(model, rows, rows[i], i); err != nil {
+			return 0, err
+		}
+	}
+	this.tables[model.Table] = rows
+	return int64(len(indexes)), nil
+}
+
+func (this *Memory) UpdateMany(ctx context.Context, model *models.Model, where []Where, update Row, mutation Mutation) (int64, error) {
+	clauses, err := parseWhere(model, where)
+	if err != nil {
+		return 0, err
+	}
+	if err := checkUpdate(model, update); err != nil {
+		return 0, err
+	}
+	defer this.lock()()
+
+	matched := this.matching(model, clauses, true)
+	if err := checkMutation(mutation, len(matched)); err != nil || mutation.DryRun {
+		return int64(len(matched)), err
+	}
+	return this.apply(model, matched, func(row Row) {
+		for column, value := range update {
+			if _, ok := model.Field(column); ok {
+				row[column] = value
+			}
+		}
+	})
+}
+
+func (this *Memory) matching(model *models.Model, clauses []clause, includeDeleted bool) []int {
+	var matched []int
+	for i, row := range this.tables[model.Table] {
+		if matches(clauses, row) && visible(model, row, includeDeleted) {
+			matched = append(matched, i)
+		}
+	}
+	return matched
+}
+
+func checkMutation(mutation Mutation, count int) error {
+	if mutation.Limit > 0 && int64(count) > mutation.Limit {
+		return &LimitError{Count: int64(count), Limit: mutation.Limit}
+	}
+	return nil
+}
+
+func (this *Memory) Delete(ctx context.Context, model *models.Model, where []Where) error {
+	clauses, err := parseWhere(model, where)
+	if err != nil {
+		return err
+	}
+	defer this.lock()()
+
+	_, err = this.remove(model, clauses, false)
+	return err
+}
+
+func (this *Memory) DeleteMany(ctx context.Context, model *models.Model, where []Where, mutation Mutation) (int64, error) {
+	clauses, err := parseWhere(model, where)
+	if err != nil {
+		return 0, err
+	}
+	defer this.lock()()
+
+	permanent := !model.Options.SoftDelete || mutation.Permanent
+	matched := this.matching(model, clauses, permanent)
+	if err := checkMutation(mutation, len(matched)); err != nil || mutation.DryRun {
+		return int64(len(matched)), err
+	}
+	return this.remove(model, clauses, mutation.Permanent)
+}
+
+// remove deletes the matching rows, or stamps deletedAt on the live ones of
+// soft-delete models. The caller holds the lock.
+func (this *Memory) remove(model *models.Model, clauses []clause, permanent bool) (int64, error) {
+	if model.Options.SoftDelete && !permanent {
+		now := time.Now().UTC()
+		return this.apply(model, this.matching(model, clauses, false), func(row Row) {
+			row[models.DeletedAtColumn] = now
+		})
+	}
+
+	var kept []Row
+	var count int64
+	for _, row := range this.tables[model.Table] {
+		if matches(clauses, row) {
+			count++
+		} else {
+			kept = append(kept, row)
+		}
+	}
+	this.tables[model.Table] = kept
+	return count, nil
+}
+
+// Transaction runs fn against a copy of the tables and swaps it in when fn
+// succeeds. Transactions run one at a time.
+func (this *Memory) Transaction(ctx context.Context, fn func(tx Storage) error) error {
+	if this.mu == nil {
+		return fn(this)
+	}
+	defer this.lock()()
+
+	view := &Memory{tables: make(map[string][]Row, len(this.tables))}
+	for table, rows := range this.tables {
+		view.tables[table] = append([]Row(nil), rows...)
+	}
+
+	if err := fn(view); err != nil {
+		return err
+	}
+	this.tables = view.tables
+	return nil
+}
+
+// matches applies SQL semantics: AND clauses must all hold and at least one
+// OR clause must, and a comparison with NULL is never true.
+func matches(clauses []clause, row Row) bool {
+	anyOr, matchedOr := false, false
+	for _, c := range clauses {
+		ok := test(c, row[c.field.Column])
+		if c.or {
+			anyOr = true
+			matchedOr = matchedOr || ok
+		} else if !ok {
+			return false
+		}
+	}
+	return !anyOr || matchedOr
+}
+
+func test(c clause, value interface{}) bool {
+	switch c.operator {
+	case "eq", "ne":
+		if c.value == nil {
+			return (value == nil) == (c.operator == "eq")
+		}
+	case "in", "not_in":
+		if len(c.values) == 0 {
+			return c.operator == "not_in"
+		}
+	}
+	if value == nil {
+		return false
+	}
+
+	switch c.operator {
+	case "eq":
+		return equal(value, c.value)
+	case "ne":
+		return !equal(value, c.value)
+	case "lt", "lte", "gt", "gte":
+		order, ok := compare(value, c.value)
+		if !ok {
+			return false
+		}
+		switch c.operator {
+		case "lt":
+			return order < 0
+		case "lte":
+			return order <= 0
+		case "gt":
+			return order > 0
+		}
+		return order >= 0
+	case "in", "not_in":
+		found := false
+		for _, item := range c.values {
+			if equal(value, item) {
+				found = true
+				break
+			}
+		}
+		return found == (c.operator == "in")
+	}
+
+	s, ok := value.(string)
+	if !ok {
+		return false
+	}
+	// Case-insensitive, like LOWER(column) LIKE LOWER(pattern) on SQL.
+	s, pattern := strings.ToLower(s), strings.ToLower(c.value.(string))
+	switch c.operator {
+	case "contains":
+		return strings.Contains(s, pattern)
+	case "starts_with":
+		return strings.HasPrefix(s, pattern)
+	}
+	return strings.HasSuffix(s, pattern)
+}
+
+func equal(a interface{}, b interface{}) bool {
+	if order, ok := compare(a, b); ok {
+		return order == 0
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func toInt(value interface{}) (int64, bool) {
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(rv.Uint()), true
+	}
+	return 0, false
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	if n, ok := toInt(value); ok {
+		return float64(n), true
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+// compare orders two values of the same kind.
+func compare(a interface{}, b interface{}) (int, bool) {
+	switch x := a.(type) {
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y), true
+		}
+	case bool:
+		if y, ok := b.(bool); ok {
+			switch {
+			case x == y:
+				return 0, true
+			case y:
+				return -1, true
+			}
+			return 1, true
+		}
+	case time.Time:
+		if y, ok := b.(time.Time); ok {
+			return x.Compare(y), true
+		}
+	case []byte:
+		if y, ok := b.([]byte); ok {
+			return bytes.Compare(x, y), true
+		}
+	}
+
+	if x, ok := toInt(a); ok {
+		if y, ok := toInt(b); ok {
+			switch {
+			case x < y:
+				return -1, true
+			case x > y:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	if x, ok := toFloat(a); ok {
+		if y, ok := toFloat(b); ok {
+			switch {
+			case x < y:
+				return -1, true
+			case x > y:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	return 0, false
+}
+
+// sortRows orders like the SQL storage: NULLs last ascending and first
+// descending, ties kept in insertion order.
+func sortRows(rows []Row, sortBy []Sort) {
+	if len(sortBy) == 0 {
+		return
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		for _, s := range sortBy {
+			a, b := rows[i][s.Field], rows[j][s.Field]
+			var order int
+			switch {
+			case a == nil && b == nil:
+				order = 0
+			case a == nil:
+				order = 1
+			case b == nil:
+				order = -1
+			default:
+				order, _ = compare(a, b)
+			}
+			if s.Descending {
+				order = -order
+			}
+			if order != 0 {
+				return order < 0
+			}
+		}
+		return false
+	})
+}
@@ -0,0 +1,679 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"hack/backend/dialect"
+	"hack/backend/models"
+	s "hack/backend/server"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+var ErrNotConnected = s.ErrNotConnected
+
+// executor is satisfied by *server.Database and *server.Tx, which both
+// take canonical SQL.
+type executor interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*s.Rows, error)
+}
+
+// SQL keeps rows in the database behind a server.Database.
+type SQL struct {
+	DB *s.Database
+	tx *s.Tx // set inside Transaction
+}
+
+func NewSQL(db *s.Database) *SQL {
+	return &SQL{DB: db}
+}
+
+// executor connects on first use, like the handlers always did.
+func (this *SQL) executor() (executor, error) {
+	if this.tx != nil {
+		return this.tx, nil
+	}
+	this.DB.Connect()
+	if !this.DB.IsConnected() {
+		return nil, ErrNotConnected
+	}
+	return this.DB, nil
+}
+
+// reader is where FindMany and Count run: the transaction, or a read
+// replica when the database has healthy ones.
+func (this *SQL) reader(ctx context.Context) (executor, error) {
+	if this.tx != nil {
+		return this.tx, nil
+	}
+	if _, err := this.executor(); err != nil {
+		return nil, err
+	}
+	return this.DB.Reader(ctx), nil
+}
+
+var deletedAtColumn = models.QuoteIdent(models.DeletedAtColumn)
+
+// whereSQL renders clauses with ? placeholders.
+func whereSQL(d dialect.Dialect, clauses []clause) (string, []interface{}) {
+	var andParts []string
+	var orParts []string
+	var args []interface{}
+
+	placeholder := func(value interface{}) string {
+		args = append(args, value)
+		return "?"
+	}
+
+	for _, c := range clauses {
+		column := models.QuoteIdent(c.field.Column)
+
+		var part string
+		switch c.operator {
+		case "eq", "ne", "lt", "lte", "gt", "gte":
+			switch {
+			case c.value == nil && c.operator == "eq":
+				part = column + " IS NULL"
+			case c.value == nil:
+				part = column + " IS NOT NULL"
+			default:
+				part = fmt.Sprintf("%s %s %s", column, comparisons[c.operator], placeholder(c.value))
+			}
+		case "in", "not_in":
+			if len(c.values) == 0 {
+				if c.operator == "in" {
+					part = "FALSE"
+				} else {
+					part = "TRUE"
+				}
+				break
+			}
+			placeholders := make([]string, len(c.values))
+			for i, value := range c.values {
+				placeholders[i] = placeholder(value)
+			}
+			sqlOperator := "IN"
+			if c.operator == "not_in" {
+				sqlOperator = "NOT IN"
+			}
+			part = fmt.Sprintf("%s %s (%s)", column, sqlOperator, strings.Join(placeholders, ", "))
+		case "contains", "starts_with", "ends_with":
+			part = fmt.Sprintf("LOWER(%s) LIKE LOWER(%s) ESCAPE %s", column, placeholder(likePattern(c.operator, c.value.(string))), likeEscape(d))
+		}
+
+		if c.or {
+			orParts = append(orParts, part)
+		} else {
+			andParts = append(andParts, part)
+		}
+	}
+
+	if len(orParts) > 0 {
+		andParts = append(andParts, "("+strings.Join(orParts, " OR ")+")")
+	}
+	return strings.Join(andParts, " AND "), args
+}
+
+// likePattern matches value literally, anywhere in the column for contains
+// or at its start or end. The escape character is a backslash.
+func likePattern(operator string, value string) string {
+	value = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+	switch operator {
+	case "contains":
+		return "%" + value + "%"
+	case "starts_with":
+		return value + "%"
+	}
+	return "%" + value
+}
+
+// likeEscape is the ESCAPE literal for a backslash, which MySQL string
+// literals escape too.
+func likeEscape(d dialect.Dialect) string {
+	if d.Name() == "mysql" {
+		return `'\\'`
+	}
+	return `'\'`
+}
+
+var comparisons = map[string]string{
+	"eq":  "=",
+	"ne":  "<>",
+	"lt":  "<",
+	"lte": "<=",
+	"gt":  ">",
+	"gte": ">=",
+}
+
+// withWhere appends the where clause to query when there is one.
+func withWhere(query string, whereClause string) string {
+	if whereClause == "" {
+		return query
+	}
+	return query + " WHERE " + whereClause
+}
+
+// excludeDeleted narrows whereClause to rows that are not soft-deleted.
+func excludeDeleted(model *models.Model, whereClause string, includeDeleted bool) string {
+	if !model.Options.SoftDelete || includeDeleted {
+		return whereClause
+	}
+
+	condition := deletedAtColumn + " IS NULL"
+	if whereClause == "" {
+		return condition
+	}
+	return fmt.Sprintf("(%s) AND %s", whereClause, condition)
+}
+
+// orderSQL sorts NULLs last ascending and first descending, as Postgres
+// does, on every dialect.
+func orderSQL(sortBy []Sort) string {
+	if len(sortBy) == 0 {
+		return ""
+	}
+	parts := make([]string, len(sortBy))
+	for i, sort := range sortBy {
+		column := models.QuoteIdent(sort.Field)
+		if sort.Descending {
+			parts[i] = fmt.Sprintf("(%s IS NULL) DESC, %s DESC", column, column)
+		} else {
+			parts[i] = fmt.Sprintf("(%s IS NULL), %s ASC", column, column)
+		}
+	}
+	return " ORDER BY " + strings.Join(parts, ", ")
+}
+
+// pageSQL limits the rows. An offset alone still needs a LIMIT on SQLite
+// and MySQL.
+func pageSQL(limit int, offset int) string {
+	var page string
+	if limit > 0 {
+		page = fmt.Sprintf(" LIMIT %d", limit)
+	} else if offset > 0 {
+		page = fmt.Sprintf(" LIMIT %d", math.MaxInt64)
+	}
+	if offset > 0 {
+		page += fmt.Sprintf(" OFFSET %d", offset)
+	}
+	return page
+}
+
+// quotedColumns lists the model's columns for a SELECT.
+func quotedColumns(model *models.Model) string {
+	columns := model.Columns()
+	for i, column := range columns {
+		columns[i] = models.QuoteIdent(column)
+	}
+	return strings.Join(columns, ", ")
+}
+
+// scanRow reads the current row, converting values back to the model's
+// field types. Text in columns the model does not know is read as a string.
+func scanRow(model *models.Model, rows *s.Rows) (Row, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]interface{}, len(columns))
+	valuePtrs := make([]interface{}, len(columns))
+	for i := range columns {
+		valuePtrs[i] = &values[i]
+	}
+
+	if err := rows.Scan(valuePtrs...); err != nil {
+		return nil, err
+	}
+
+	result := make(Row)
+	for i, col := range columns {
+		val := values[i]
+		if field, ok := model.Field(col); ok {
+			val = field.FromDB(val)
+		} else if b, ok := val.([]byte); ok {
+			val = string(b)
+		}
+		result[col] = val
+	}
+	return result, nil
+}
+
+func scanRows(model *models.Model, rows *s.Rows) ([]Row, error) {
+	defer rows.Close()
+
+	var results []Row
+	for rows.Next() {
+		row, err := scanRow(model, rows)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, row)
+	}
+	return results, rows.Err()
+}
+
+// assignments renders the SET list of an update in field order.
+func assignments(model *models.Model, update Row) ([]string, []interface{}) {
+	var sets []string
+	var args []interface{}
+	for _, field := range model.Fields {
+		if value, ok := update[field.Column]; ok {
+			sets = append(sets, models.QuoteIdent(field.Column)+" = ?")
+			args = append(args, value)
+		}
+	}
+	return sets, args
+}
+
+func (this *SQL) Create(ctx context.Context, model *models.Model, data Row) (Row, error) {
+	exec, err := this.executor()
+	if err != nil {
+		return nil, err
+	}
+
+	var columns, placeholders []string
+	var values []interface{}
+	for _, field := range model.Fields {
+		if value, ok := data[field.Column]; ok {
+			columns = append(columns, models.QuoteIdent(field.Column))
+			placeholders = append(placeholders, "?")
+			values = append(values, value)
+		}
+	}
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", model.QuotedTable(), strings.Join(columns, ", "), strings.Join(placeholders, ", "))
+
+	// Return the stored row, with the defaults the database filled in.
+	var rows *s.Rows
+	if this.DB.Dialect().SupportsReturning() {
+		rows, err = exec.QueryContext(ctx, query+" RETURNING "+quotedColumns(model), values...)
+	} else {
+		rows, err = this.insertAndSelect(ctx, exec, model, query, values, data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := scanRows(model, rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(created) == 0 {
+		return data, nil
+	}
+	return created[0], nil
+}
+
+// insertAndSelect runs an INSERT and reads the row back by its primary key,
+// for dialects without RETURNING. A single primary key missing from data is
+// taken from the last insert id.
+func (this *SQL) insertAndSelect(ctx context.Context, exec executor, model *models.Model, query string, values []interface{}, data Row) (*s.Rows, error) {
+	var keys []*models.Field
+	for _, field := range model.Fields {
+		if field.PrimaryKey {
+			keys = append(keys, field)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("model '%s' has no primary key to read the row back", model.Name)
+	}
+
+	result, err := exec.ExecContext(ctx, query, values...)
+	if err != nil {
+		return nil, err
+	}
+
+	var conditions []string
+	var args []interface{}
+	for _, field := range keys {
+		value, ok := data[field.Column]
+		if !ok {
+			if value, err = result.LastInsertId(); err != nil {
+				return nil, err
+			}
+		}
+		conditions = append(conditions, models.QuoteIdent(field.Column)+" = ?")
+		args = append(args, value)
+	}
+
+	return exec.QueryContext(ctx, fmt.Sprintf("SELECT %s FROM %s WHERE %s", quotedColumns(model), model.QuotedTable(), strings.Join(conditions, " AND ")), args...)
+}
+
+func (this *SQL) FindOne(ctx context.Context, model *models.Model, query Query) (Row, error) {
+	query.Limit = 1
+	rows, err := this.FindMany(ctx, model, query)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, ErrNotFound
+	}
+	return rows[0], nil
+}
+
+func (this *SQL) FindMany(ctx context.Context, model *models.Model, query Query) ([]Row, error) {
+	clauses, err := parseWhere(model, query.Where)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkQuery(model, query); err != nil {
+		return nil, err
+	}
+	exec, err := this.reader(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	whereClause, args := whereSQL(this.DB.Dialect(), clauses)
+	sqlQuery := withWhere(
+		fmt.Sprintf("SELECT %s FROM %s", quotedColumns(model), model.QuotedTable()),
+		excludeDeleted(model, whereClause, query.IncludeDeleted),
+	) + orderSQL(query.SortBy) + pageSQL(query.Limit, query.Offset)
+
+	rows, err := exec.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanRows(model, rows)
+}
+
+func (this *SQL) Count(ctx context.Context, model *models.Model, query Query) (int64, error) {
+	clauses, err := parseWhere(model, query.Where)
+	if err != nil {
+		return 0, err
+	}
+	exec, err := this.reader(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	whereClause, args := whereSQL(this.DB.Dialect(), clauses)
+	sqlQuery := withWhere("SELECT COUNT(*) FROM "+model.QuotedTable(), excludeDeleted(model, whereClause, query.IncludeDeleted))
+
+	return countRows(ctx, exec, sqlQuery, args)
+}
+
+// countRows runs a SELECT COUNT(*) query.
+func countRows(ctx context.Context, exec executor, query string, args []interface{}) (int64, error) {
+	rows, err := exec.QueryContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var count int64
+	if rows.Next() {
+		if err := rows.Scan(&count); err != nil {
+			return 0, err
+		}
+	}
+	return count, rows.Err()
+}
+
+func (this *SQL) Update(ctx context.Context, model *models.Model, where []Where, update Row, ifMatch *IfMatch) (int64, error) {
+	clauses, err := parseWhere(model, where)
+	if err != nil {
+		return 0, err
+	}
+	sets, args := assignments(model, update)
+	if len(sets) == 0 {
+		return 0, invalid("nothing to update")
+	}
+	setArgs := len(args)
+
+	whereClause, whereArgs := whereSQL(this.DB.Dialect(), clauses)
+	args = append(args, whereArgs...)
+
+	// Optimistic concurrency: only apply when the row is still at the expected version.
+	if ifMatch != nil {
+		var condition string
+		switch {
+		case ifMatch.Version != nil:
+			if _, ok := model.Field("version"); !ok {
+				return 0, invalid("Model '%s' has no version field", model.Name)
+			}
+			condition = "\"version\" = ?"
+			args = append(args, *ifMatch.Version)
+			if _, ok := update["version"]; !ok {
+				sets = append(sets, "\"version\" = \"version\" + 1")
+			}
+		case ifMatch.UpdatedAt != nil:
+			condition = "\"updatedAt\" = ?"
+			args = append(args, *ifMatch.UpdatedAt)
+		default:
+			return 0, invalid("ifMatch requires updatedAt or version")
+		}
+
+		if whereClause != "" {
+			whereClause = fmt.Sprintf("(%s) AND %s", whereClause, condition)
+		} else {
+			whereClause = condition
+		}
+	}
+
+	exec, err := this.executor()
+	if err != nil {
+		return 0, err
+	}
+
+	sqlQuery := withWhere(fmt.Sprintf("UPDATE %s SET %s", model.QuotedTable(), strings.Join(sets, ", ")), whereClause)
+	result, err := exec.ExecContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil || ifMatch == nil || affected > 0 {
+		return affected, err
+	}
+
+	// MySQL's RowsAffected leaves out a matched row the update did not
+	// change, so it is only a conflict once the row no longer matches.
+	matched, err := countRows(ctx, exec, withWhere("SELECT COUNT(*) FROM "+model.QuotedTable(), whereClause), args[setArgs:])
+	if err != nil || matched > 0 {
+		return matched, err
+	}
+
+	// From the primary, a replica may not have the write that conflicted.
+	current, err := this.FindOne(s.WithPrimary(ctx), model, Query{Where: where, IncludeDeleted: true})
+	if err != nil {
+		return 0, err
+	}
+	return 0, &ConflictError{Current: current}
+}
+
+func (this *SQL) UpdateMany(ctx context.Context, model *models.Model, where []Where, update Row, mutation Mutation) (int64, error) {
+	clauses, err := parseWhere(model, where)
+	if err != nil {
+		return 0, err
+	}
+	sets, args := assignments(model, update)
+	if len(sets) == 0 {
+		return 0, invalid("nothing to update")
+	}
+
+	whereClause, whereArgs := whereSQL(this.DB.Dialect(), clauses)
+	sqlQuery := withWhere(fmt.Sprintf("UPDATE %s SET %s", model.QuotedTable(), strings.Join(sets, ", ")), whereClause)
+	return this.guarded(ctx, mutation, model, whereClause, whereArgs, sqlQuery, append(args, whereArgs...))
+}
+
+// deleteSQL turns a delete of soft-delete rows into an update stamping
+// deletedAt, unless it is permanent.
+func deleteSQL(model *models.Model, whereClause string, args []interface{}, permanent bool) (string, []interface{}) {
+	if !model.Options.SoftDelete || permanent {
+		return withWhere("DELETE FROM "+model.QuotedTable(), whereClause), args
+	}
+
+	sqlQuery := withWhere(
+		fmt.Sprintf("UPDATE %s SET %s = ?", model.QuotedTable(), deletedAtColumn),
+		excludeDeleted(model, whereClause, false),
+	)
+	return sqlQuery, append([]interface{}{time.Now()}, args...)
+}
+
+func (this *SQL) Delete(ctx context.Context, model *models.Model, where []Where) error {
+	clauses, err := parseWhere(model, where)
+	if err != nil {
+		return err
+	}
+	exec, err := this.executor()
+	if err != nil {
+		return err
+	}
+
+	whereClause, args := whereSQL(this.DB.Dialect(), clauses)
+	sqlQuery, args := deleteSQL(model, whereClause, args, false)
+	_, err = exec.ExecContext(ctx, sqlQuery, args...)
+	return err
+}
+
+func (this *SQL) DeleteMany(ctx context.Context, model *models.Model, where []Where, mutation Mutation) (int64, error) {
+	clauses, err := parseWhere(model, where)
+	if err != nil {
+		return 0, err
+	}
+
+	whereClause, whereArgs := whereSQL(this.DB.Dialect(), clauses)
+	sqlQuery, args := deleteSQL(model, whereClause, whereArgs, mutation.Permanent)
+	if model.Options.SoftDelete && !mutation.Permanent {
+		whereClause = excludeDeleted(model, whereClause, false)
+	}
+	return this.guarded(ctx, mutation, model, whereClause, whereArgs, sqlQuery, args)
+}
+
+// guarded runs a mass update or delete and keeps it only when the matched
+// row count stays under the limit and it is not a dry run. Inside a
+// transaction a savepoint takes the place of the transaction.
+//
+// Rows are counted with whereClause first: MySQL's RowsAffected leaves out
+// matched rows the update did not change.
+func (this *SQL) guarded(ctx context.Context, mutation Mutation, model *models.Model, whereClause string, whereArgs []interface{}, query string, args []interface{}) (int64, error) {
+	var affected int64
+	check := func(tx Storage) error {
+		exec := tx.(*SQL).tx
+		var err error
+		affected, err = countRows(ctx, exec, withWhere("SELECT COUNT(*) FROM "+model.QuotedTable(), whereClause), whereArgs)
+		if err != nil {
+			return err
+		}
+		if mutation.Limit > 0 && affected > mutation.Limit {
+			return &LimitError{Count: affected, Limit: mutation.Limit}
+		}
+		if mutation.DryRun {
+			return errDryRun
+		}
+
+		result, err := exec.ExecContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		// Rows committed since the count were matched too.
+		if changed, err := result.RowsAffected(); err != nil {
+			return err
+		} else if changed > affected {
+			affected = changed
+		}
+		if mutation.Limit > 0 && affected > mutation.Limit {
+			return &LimitError{Count: affected, Limit: mutation.Limit}
+		}
+		return nil
+	}
+
+	var err error
+	if this.tx == nil {
+		err = this.Transaction(ctx, check)
+	} else {
+		err = this.savepoint(ctx, func() error { return check(this) })
+	}
+	if err == errDryRun {
+		err = nil
+	}
+	return affected, err
+}
+
+var errDryRun = errors.New("dry run")
+
+func (this *SQL) savepoint(ctx context.Context, fn func() error) error {
+	if _, err := this.tx.ExecContext(ctx, "SAVEPOINT storage_guard"); err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		if _, rollbackErr := this.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT storage_guard"); rollbackErr != nil {
+			return rollbackErr
+		}
+		return err
+	}
+	_, err := this.tx.ExecContext(ctx, "RELEASE SAVEPOINT storage_guard")
+	return err
+}
+
+// Transaction runs fn in a database transaction. Nested transactions join
+// the outer one.
+func (this *SQL) Transaction(ctx context.Context, fn func(tx Storage) error) error {
+	if this.tx != nil {
+		return fn(this)
+	}
+	if _, err := this.executor(); err != nil {
+		return err
+	}
+
+	tx, err := this.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(&SQL{DB: this.DB, tx: tx}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Violation reports the constraint err breaks, from either storage and
+// any of the database drivers.
+func Violation(err error) (*ConstraintError, bool) {
+	var constraint *ConstraintError
+	if errors.As(err, &constraint) {
+		return constraint, true
+	}
+
+	var kind Constraint
+	var pgErr *pgconn.PgError
+	var sqliteErr *sqlite.Error
+	var mysqlErr *mysql.MySQLError
+	switch {
+	case errors.As(err, &pgErr):
+		kind = map[string]Constraint{"23505": Unique, "23503": ForeignKey, "23502": NotNull}[pgErr.Code]
+	case errors.As(err, &sqliteErr):
+		switch sqliteErr.Code() {
+		case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+			kind = Unique
+		case sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY:
+			kind = ForeignKey
+		case sqlite3.SQLITE_CONSTRAINT_NOTNULL:
+			kind = NotNull
+		}
+	case errors.As(err, &mysqlErr):
+		switch mysqlErr.Number {
+		case 1062:
+			kind = Unique
+		case 1216, 1217, 1451, 1452:
+			kind = ForeignKey
+		case 1048, 1364:
+			kind = NotNull
+		}
+	}
+	if kind == "" {
+		return nil, false
+	}
+	return &ConstraintError{Constraint: kind, Err: err}, true
+}
@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"hack/backend/models"
+)
+
+// Row is a record as column -> value, with values of the field types.
+type Row = map[string]interface{}
+
+// Where is one clause of a Better Auth where list.
+type Where struct {
+	Operator  string      `json:"operator"`
+	Connector string      `json:"connector"`
+	Field     string      `json:"field"`
+	Value     interface{} `json:"value"`
+}
+
+// Sort orders results by a field.
+type Sort struct {
+	Field      string
+	Descending bool
+}
+
+// Query selects rows of a model.
+type Query struct {
+	Where  []Where
+	SortBy []Sort
+	// Limit caps the number of rows, zero for no cap.
+	Limit  int
+	Offset int
+	// IncludeDeleted also matches soft-deleted rows.
+	IncludeDeleted bool
+}
+
+// IfMatch is the expected state of a row for an optimistic update,
+// either its updatedAt timestamp or its version counter.
+type IfMatch struct {
+	UpdatedAt *time.Time `json:"updatedAt"`
+	Version   *int64     `json:"version"`
+}
+
+// Mutation tunes UpdateMany and DeleteMany.
+type Mutation struct {
+	// Limit rolls the mutation back when more rows would change, zero or
+	// negative for no limit.
+	Limit int64
+	// DryRun counts the rows that would change without changing them.
+	DryRun bool
+	// Permanent removes rows of soft-delete models, including soft-deleted
+	// ones, instead of stamping deletedAt.
+	Permanent bool
+}
+
+// Storage is what the adapter endpoints and the admin need from a backend.
+// Data passed in is already coerced to the field types.
+type Storage interface {
+	Create(ctx context.Context, model *models.Model, data Row) (Row, error)
+	// FindOne returns ErrNotFound when no row matches.
+	FindOne(ctx context.Context, model *models.Model, query Query) (Row, error)
+	FindMany(ctx context.Context, model *models.Model, query Query) ([]Row, error)
+	Count(ctx context.Context, model *models.Model, query Query) (int64, error)
+	// Update changes every row matching where, soft-deleted or not. With
+	// ifMatch set it returns a *ConflictError when the rows moved on, or
+	// ErrNotFound when none match at all.
+	Update(ctx context.Context, model *models.Model, where []Where, update Row, ifMatch *IfMatch) (int64, error)
+	// UpdateMany returns a *LimitError, leaving the rows alone, when more
+	// rows than the mutation's limit would change.
+	UpdateMany(ctx context.Context, model *models.Model, where []Where, update Row, mutation Mutation) (int64, error)
+	// Delete soft-deletes the matching rows of soft-delete models.
+	Delete(ctx context.Context, model *models.Model, where []Where) error
+	DeleteMany(ctx context.Context, model *models.Model, where []Where, mutation Mutation) (int64, error)
+	// Transaction runs fn against a storage whose changes are kept only if
+	// fn returns nil.
+	Transaction(ctx context.Context, fn func(tx Storage) error) error
+}
+
+var ErrNotFound = errors.New("not found")
+
+// InvalidError is a request the storage refused before touching any data,
+// such as an unknown field or operator.
+type InvalidError struct {
+	Err error
+}
+
+func (this *InvalidError) Error() string {
+	return this.Err.Error()
+}
+
+func (this *InvalidError) Unwrap() error {
+	return this.Err
+}
+
+func invalid(format string, args ...interface{}) error {
+	return &InvalidError{Err: fmt.Errorf(format, args...)}
+}
+
+// ConflictError is an ifMatch update whose row no longer matches.
+type ConflictError struct {
+	Current Row
+}
+
+func (this *ConflictError) Error() string {
+	return "precondition failed"
+}
+
+// ConstraintError is a write refused for breaking a unique key, a foreign
+// key or a NOT NULL column.
+type ConstraintError struct {
+	Constraint Constraint
+	Err        error
+}
+
+type Constraint string
+
+const (
+	Unique     Constraint = "unique"
+	ForeignKey Constraint = "foreign key"
+	NotNull    Constraint = "not null"
+)
+
+func (this *ConstraintError) Error() string {
+	return this.Err.Error()
+}
+
+func (this *ConstraintError) Unwrap() error {
+	return this.Err
+}
+
+func violation(constraint Constraint, format string, args ...interface{}) error {
+	return &ConstraintError{Constraint: constraint, Err: fmt.Errorf(format, args...)}
+}
+
+// LimitError is a mass mutation that would change more rows than allowed.
+type LimitError struct {
+	Count int64
+	Limit int64
+}
+
+func (this *LimitError) Error() string {
+	return fmt.Sprintf("%d rows exceeds limit of %d", this.Count, this.Limit)
+}
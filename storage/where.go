@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"strings"
+
+	"hack/backend/models"
+)
+
+// clause is a where clause checked against the model, with its values
+// coerced to the field type. Both storages evaluate the same clauses.
+type clause struct {
+	field    *models.Field
+	operator string
+	value    interface{}   // nil with eq and ne tests for NULL
+	values   []interface{} // in and not_in
+	or       bool
+}
+
+var operators = map[string]bool{
+	"eq": true, "ne": true, "lt": true, "lte": true, "gt": true, "gte": true,
+	"in": true, "not_in": true, "contains": true, "starts_with": true, "ends_with": true,
+}
+
+// parseWhere checks where against the model. Like Better Auth's own
+// adapters, clauses with the AND connector are all required and clauses
+// with OR form a single alternative group.
+func parseWhere(model *models.Model, where []Where) ([]clause, error) {
+	clauses := make([]clause, 0, len(where))
+
+	for _, w := range where {
+		field, ok := model.Field(w.Field)
+		if !ok {
+			return nil, invalid("unknown field '%s' on model '%s'", w.Field, model.Name)
+		}
+		operator := strings.ToLower(w.Operator)
+		if operator == "" {
+			operator = "eq"
+		}
+		if !operators[operator] {
+			return nil, invalid("unsupported operator '%s'", w.Operator)
+		}
+
+		c := clause{field: field, operator: operator}
+		switch strings.ToUpper(w.Connector) {
+		case "", "AND":
+		case "OR":
+			c.or = true
+		default:
+			return nil, invalid("unsupported connector '%s'", w.Connector)
+		}
+
+		switch operator {
+		case "eq", "ne", "lt", "lte", "gt", "gte":
+			if w.Value == nil {
+				if operator != "eq" && operator != "ne" {
+					return nil, invalid("operator '%s' on field '%s' needs a value", operator, w.Field)
+				}
+				break
+			}
+			value, err := field.Coerce(w.Value)
+			if err != nil {
+				return nil, &InvalidError{Err: err}
+			}
+			c.value = value
+		case "in", "not_in":
+			values, ok := w.Value.([]interface{})
+			if !ok {
+				return nil, invalid("operator '%s' on field '%s' needs a list value", operator, w.Field)
+			}
+			for _, value := range values {
+				value, err := field.Coerce(value)
+				if err != nil {
+					return nil, &InvalidError{Err: err}
+				}
+				c.values = append(c.values, value)
+			}
+		default:
+			value, ok := w.Value.(string)
+			if !ok {
+				return nil, invalid("operator '%s' on field '%s' needs a string value", operator, w.Field)
+			}
+			c.value = value
+		}
+
+		clauses = append(clauses, c)
+	}
+	return clauses, nil
+}
+
+// checkQuery validates the sort fields and pagination of a query.
+func checkQuery(model *models.Model, query Query) error {
+	for _, sort := range query.SortBy {
+		if _, ok := model.Field(sort.Field); !ok {
+			return invalid("unknown field '%s' on model '%s'", sort.Field, model.Name)
+		}
+	}
+	if query.Limit < 0 || query.Offset < 0 {
+		return invalid("limit and offset cannot be negative")
+	}
+	return nil
+}
@@ -1,35 +1,40 @@
+// Package utils logs to stderr, leaving stdout to commands that print their
+// output, such as generate-models.
 package utils
 
-import "fmt"
+import (
+	"fmt"
+	"os"
+)
 
 func Error(msg any) {
-	fmt.Printf("\033[91m [ERROR] %s \033[0m\n", msg)
+	fmt.Fprintf(os.Stderr, "\033[91m [ERROR] %s \033[0m\n", msg)
 }
 
 func Info(msg any) {
-	fmt.Printf("\033[94m [INFO] %s \033[0m\n", msg)
+	fmt.Fprintf(os.Stderr, "\033[94m [INFO] %s \033[0m\n", msg)
 }
 
 func Warn(msg any) {
-	fmt.Printf("\033[93m [WARN] %s \033[0m\n", msg)
+	fmt.Fprintf(os.Stderr, "\033[93m [WARN] %s \033[0m\n", msg)
 }
 
 func Debug(msg any) {
-	fmt.Printf("\033[36m [DEBUG] %s \033[0m\n", msg)
+	fmt.Fprintf(os.Stderr, "\033[36m [DEBUG] %s \033[0m\n", msg)
 }
 
 func ErrorF(msg string, args ...any) {
-	fmt.Printf("\033[91m [ERROR] %s \033[0m\n", fmt.Sprintf(msg, args...))
+	fmt.Fprintf(os.Stderr, "\033[91m [ERROR] %s \033[0m\n", fmt.Sprintf(msg, args...))
 }
 
 func InfoF(msg string, args ...any) {
-	fmt.Printf("\033[94m [INFO] %s \033[0m\n", fmt.Sprintf(msg, args...))
+	fmt.Fprintf(os.Stderr, "\033[94m [INFO] %s \033[0m\n", fmt.Sprintf(msg, args...))
 }
 
 func WarnF(msg string, args ...any) {
-	fmt.Printf("\033[93m [WARN] %s \033[0m\n", fmt.Sprintf(msg, args...))
+	fmt.Fprintf(os.Stderr, "\033[93m [WARN] %s \033[0m\n", fmt.Sprintf(msg, args...))
 }
 
 func DebugF(msg string, args ...any) {
-	fmt.Printf("\033[36m [DEBUG] %s \033[0m\n", fmt.Sprintf(msg, args...))
+	fmt.Fprintf(os.Stderr, "\033[36m [DEBUG] %s \033[0m\n", fmt.Sprintf(msg, args...))
 }